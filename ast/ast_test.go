@@ -2,6 +2,7 @@ package ast
 
 import (
 	"GoClang/token"
+	"bytes"
 	"testing"
 )
 
@@ -26,3 +27,46 @@ func TestString(t *testing.T) {
 		t.Errorf("Program String() wrong. got %q", program.String())
 	}
 }
+
+func TestFprint(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Pos: token.Position{Line: 1, Column: 1}},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "x", Pos: token.Position{Line: 1, Column: 5}},
+					Value: "x",
+				},
+				Value: &ArrayLiteral{
+					Token: token.Token{Type: token.LBRACKET, Literal: "[", Pos: token.Position{Line: 1, Column: 9}},
+				},
+			},
+		},
+	}
+
+	expected := `*ast.Program {
+  Statements: []ast.Statement (len=1) {
+    0: *ast.LetStatement {
+      Token: LET "let" 1:1
+      Name: *ast.Identifier {
+        Token: IDENT "x" 1:5
+        Value: "x"
+      }
+      Value: *ast.ArrayLiteral {
+        Token: [ "[" 1:9
+        Elements: []ast.Expression {}
+        Rbracket: -
+      }
+    }
+  }
+}
+`
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"GoClang/token"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var (
+	tokenType    = reflect.TypeOf(token.Token{})
+	positionType = reflect.TypeOf(token.Position{})
+)
+
+// Fprint writes node to w as an indented tree with one field per line. It is
+// meant for debugging; tokens are shown as TYPE "literal" line:col and
+// comments are left out.
+func Fprint(w io.Writer, node Node) error {
+	var buf bytes.Buffer
+	printValue(&buf, reflect.ValueOf(node), 0)
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func printValue(buf *bytes.Buffer, v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		buf.WriteString("nil")
+
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			buf.WriteString("*")
+		}
+		printValue(buf, v.Elem(), depth)
+
+	case reflect.Struct:
+		switch v.Type() {
+		case tokenType:
+			tok := v.Interface().(token.Token)
+			if tok.Type == "" {
+				buf.WriteString("-")
+				return
+			}
+			fmt.Fprintf(buf, "%s %q %s", tok.Type, tok.Literal, tok.Pos)
+			return
+		case positionType:
+			buf.WriteString(v.Interface().(token.Position).String())
+			return
+		}
+
+		fmt.Fprintf(buf, "%s {\n", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			indent(buf, depth+1)
+			buf.WriteString(field.Name + ": ")
+			printValue(buf, v.Field(i), depth+1)
+			buf.WriteByte('\n')
+		}
+		indent(buf, depth)
+		buf.WriteString("}")
+
+	case reflect.Slice:
+		if v.Len() == 0 {
+			fmt.Fprintf(buf, "%s {}", v.Type())
+			return
+		}
+		fmt.Fprintf(buf, "%s (len=%d) {\n", v.Type(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			indent(buf, depth+1)
+			fmt.Fprintf(buf, "%d: ", i)
+			printValue(buf, v.Index(i), depth+1)
+			buf.WriteByte('\n')
+		}
+		indent(buf, depth)
+		buf.WriteString("}")
+
+	case reflect.String:
+		fmt.Fprintf(buf, "%q", v.String())
+
+	default:
+		fmt.Fprintf(buf, "%v", v.Interface())
+	}
+}
+
+func indent(buf *bytes.Buffer, depth int) {
+	for i := 0; i < depth; i++ {
+		buf.WriteString("  ")
+	}
+}
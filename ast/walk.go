@@ -0,0 +1,277 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for each node found by Walk. If the
+// returned visitor w is not nil, Walk visits each child of node with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, children in
+// source order. It calls v.Visit(node); if that returns a visitor w, Walk
+// walks each non-nil child of node with w and then calls w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *LetStatement:
+		Walk(v, n.Name)
+		walkExpression(v, n.Value)
+
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *WhileStatement:
+		walkExpression(v, n.Condition)
+		Walk(v, n.Body)
+
+	case *ForStatement:
+		Walk(v, n.Variable)
+		walkExpression(v, n.Iterable)
+		Walk(v, n.Body)
+
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *AssignExpression:
+		walkExpression(v, n.Target)
+		walkExpression(v, n.Value)
+
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			Walk(v, p)
+			if i < len(n.Defaults) {
+				walkExpression(v, n.Defaults[i])
+			}
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+		Walk(v, n.Body)
+
+	case *SpreadExpression:
+		walkExpression(v, n.Value)
+
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean,
+		*BreakStatement, *ContinueStatement, *BadStatement, *BadExpression:
+		// leaves
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, s := range stmts {
+		if s != nil {
+			Walk(v, s)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, exprs []Expression) {
+	for _, e := range exprs {
+		walkExpression(v, e)
+	}
+}
+
+func walkExpression(v Visitor, e Expression) {
+	if e != nil {
+		Walk(v, e)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order, calling
+// f(node) for each node. If f returns true, Inspect goes on to the children
+// of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Modify rewrites the tree rooted at node bottom-up: the children of each
+// node are modified first, in source order, and then the node itself is
+// replaced by f(node). Nodes are updated in place and the new root is
+// returned. Replacing an expression with a statement, or an identifier that
+// names a binding with anything but an identifier, panics.
+func Modify(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		modifyStatements(n.Statements, f)
+
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, f)
+		n.Value = modifyExpression(n.Value, f)
+
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, f)
+
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, f)
+
+	case *BlockStatement:
+		modifyStatements(n.Statements, f)
+
+	case *WhileStatement:
+		n.Condition = modifyExpression(n.Condition, f)
+		n.Body = modifyBlock(n.Body, f)
+
+	case *ForStatement:
+		n.Variable = modifyIdentifier(n.Variable, f)
+		n.Iterable = modifyExpression(n.Iterable, f)
+		n.Body = modifyBlock(n.Body, f)
+
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, f)
+
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, f)
+		n.Right = modifyExpression(n.Right, f)
+
+	case *AssignExpression:
+		n.Target = modifyExpression(n.Target, f)
+		n.Value = modifyExpression(n.Value, f)
+
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, f)
+		n.Consequence = modifyBlock(n.Consequence, f)
+		if n.Alternative != nil {
+			n.Alternative = modifyBlock(n.Alternative, f)
+		}
+
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, f)
+			if i < len(n.Defaults) {
+				n.Defaults[i] = modifyExpression(n.Defaults[i], f)
+			}
+		}
+		if n.Rest != nil {
+			n.Rest = modifyIdentifier(n.Rest, f)
+		}
+		n.Body = modifyBlock(n.Body, f)
+
+	case *SpreadExpression:
+		n.Value = modifyExpression(n.Value, f)
+
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, f)
+		modifyExpressions(n.Arguments, f)
+
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, f)
+
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, f)
+		n.Index = modifyExpression(n.Index, f)
+
+	case *HashLiteral:
+		for i, pair := range n.Pairs {
+			n.Pairs[i].Key = modifyExpression(pair.Key, f)
+			n.Pairs[i].Value = modifyExpression(pair.Value, f)
+		}
+	}
+
+	return f(node)
+}
+
+func modifyStatements(stmts []Statement, f func(Node) Node) {
+	for i, s := range stmts {
+		if s == nil {
+			continue
+		}
+		replaced := Modify(s, f)
+		stmt, ok := replaced.(Statement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Modify: statement replaced by %T", replaced))
+		}
+		stmts[i] = stmt
+	}
+}
+
+func modifyExpressions(exprs []Expression, f func(Node) Node) {
+	for i, e := range exprs {
+		exprs[i] = modifyExpression(e, f)
+	}
+}
+
+func modifyExpression(e Expression, f func(Node) Node) Expression {
+	if e == nil {
+		return nil
+	}
+	replaced := Modify(e, f)
+	expr, ok := replaced.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: expression replaced by %T", replaced))
+	}
+	return expr
+}
+
+func modifyIdentifier(ident *Identifier, f func(Node) Node) *Identifier {
+	replaced := Modify(ident, f)
+	id, ok := replaced.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: identifier replaced by %T", replaced))
+	}
+	return id
+}
+
+func modifyBlock(block *BlockStatement, f func(Node) Node) *BlockStatement {
+	replaced := Modify(block, f)
+	b, ok := replaced.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: block replaced by %T", replaced))
+	}
+	return b
+}
@@ -0,0 +1,148 @@
+package ast_test
+
+import (
+	"GoClang/ast"
+	"GoClang/lexer"
+	"GoClang/parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParserProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestInspect(t *testing.T) {
+	input := `let f = fn(a, b) { if (a < b) { a } else { b[0] = -1 } };
+while (true) { break }
+for (x in [1, 2.5]) { continue }
+f({"k": x, "j": "v"}, 3)`
+
+	var types []string
+	ast.Inspect(parse(t, input), func(n ast.Node) bool {
+		if n != nil {
+			types = append(types, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier", "FunctionLiteral", "Identifier", "Identifier", "BlockStatement",
+		"ExpressionStatement", "IfExpression", "InfixExpression", "Identifier", "Identifier",
+		"BlockStatement", "ExpressionStatement", "Identifier",
+		"BlockStatement", "ExpressionStatement", "AssignExpression", "IndexExpression", "Identifier",
+		"IntegerLiteral", "PrefixExpression", "IntegerLiteral",
+		"WhileStatement", "Boolean", "BlockStatement", "BreakStatement",
+		"ForStatement", "Identifier", "ArrayLiteral", "IntegerLiteral", "FloatLiteral", "BlockStatement", "ContinueStatement",
+		"ExpressionStatement", "CallExpression", "Identifier", "HashLiteral",
+		"StringLiteral", "Identifier", "StringLiteral", "StringLiteral", "IntegerLiteral",
+	}
+
+	if strings.Join(types, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong traversal.\nexpected=%v\ngot=     %v", expected, types)
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	program := parse(t, "let f = fn(x) { y }; z")
+
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FunctionLiteral); ok {
+			return false
+		}
+		if id, ok := n.(*ast.Identifier); ok {
+			idents = append(idents, id.Value)
+		}
+		return true
+	})
+
+	if strings.Join(idents, ",") != "f,z" {
+		t.Errorf("function body not pruned. got=%v", idents)
+	}
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		return nil
+	}
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+	return depthVisitor{v.depth + 1, v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	max := 0
+	// Program > ExpressionStatement > InfixExpression > InfixExpression > IntegerLiteral
+	ast.Walk(depthVisitor{maxDepth: &max}, parse(t, "1 + 2 * 3"))
+	if max != 4 {
+		t.Errorf("wrong depth. expected=4, got=%d", max)
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func(n ast.Node) ast.Node {
+		if il, ok := n.(*ast.IntegerLiteral); ok && il.Value == 1 {
+			il.Value = 2
+			il.Token.Literal = "2"
+		}
+		return n
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "2"},
+		{"1 + 1", "(2 + 2)"},
+		{"-1", "(-2)"},
+		{"a[1] = 1", "((a[2]) = 2)"},
+		{"if (1) { 1 } else { 1 }", "if2 2else2"},
+		{"let x = 1;", "let x = 2;"},
+		{"return 1;", "return 2;"},
+		{"fn(a) { 1 }", "fn(a)2"},
+		{"[1, 1]", "[2,2]"},
+		{"f(1)", "f(2)"},
+		{"while (1) { 1 }", "while2 2"},
+		{"for (x in [1]) { 1 }", "for(x in [2]) 2"},
+		{"{1: 1}", "{2:2}"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		modified := ast.Modify(program, one)
+		if modified.String() != tt.expected {
+			t.Errorf("Modify(%q) = %q, want %q", tt.input, modified.String(), tt.expected)
+		}
+	}
+}
+
+func TestModifyReplacesNodes(t *testing.T) {
+	program := parse(t, "let a = x + y; x")
+
+	// rename x to z
+	ast.Modify(program, func(n ast.Node) ast.Node {
+		if id, ok := n.(*ast.Identifier); ok && id.Value == "x" {
+			return &ast.Identifier{Token: id.Token, Value: "z"}
+		}
+		return n
+	})
+
+	if program.String() != "let a = (z + y);z" {
+		t.Errorf("wrong result. got=%q", program.String())
+	}
+}
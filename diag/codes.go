@@ -0,0 +1,43 @@
+package diag
+
+// Diagnostic codes. A code identifies a kind of problem independently of its
+// message text and never changes meaning once released; retired codes are
+// not reused.
+const (
+	// lexer
+	ErrIllegalCharacter    = "E0001"
+	ErrUnterminatedComment = "E0002"
+	ErrUnterminatedString  = "E0003"
+	ErrInvalidEscape       = "E0004"
+	ErrMalformedNumber     = "E0005"
+	ErrUnterminatedRaw     = "E0006"
+
+	// parser
+	ErrUnexpectedToken    = "E0010"
+	ErrMissingExpression  = "E0011"
+	ErrNumberOutOfRange   = "E0012"
+	ErrInvalidAssignment  = "E0013"
+	ErrLoopControlOutside = "E0014"
+	ErrInvalidParameter   = "E0015"
+
+	// evaluator
+	ErrUndefined        = "E0100"
+	ErrUndeclaredAssign = "E0101"
+	ErrTypeMismatch     = "E0102"
+	ErrUnknownOperator  = "E0103"
+	ErrDivisionByZero   = "E0104"
+	ErrNegativeShift    = "E0105"
+	ErrNotCallable      = "E0106"
+	ErrArgumentCount    = "E0107"
+	ErrArgumentType     = "E0108"
+	ErrIndexOutOfRange  = "E0109"
+	ErrNotIndexable     = "E0110"
+	ErrUnhashable       = "E0111"
+	ErrNotIterable      = "E0112"
+	ErrSyntaxErrors     = "E0113"
+	ErrNotSpreadable    = "E0114"
+	ErrRecursionDepth   = "E0115"
+	ErrStepLimit        = "E0116"
+	ErrTimeout          = "E0117"
+	ErrCanceled         = "E0118"
+)
@@ -0,0 +1,92 @@
+// Package diag describes problems found in GoClang programs, by the lexer and
+// parser or at run time, and renders them for people and for tools.
+package diag
+
+import (
+	"GoClang/token"
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Span is a range of source text. End is the position just after the last
+// character; a Span whose End is not after Start marks a single point.
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// Related is a secondary location that helps explain a diagnostic, such as
+// the opening brace of an unclosed block.
+type Related struct {
+	Span    Span
+	Message string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Code     string // stable identifier such as "E0010", see codes.go
+	Message  string
+	Span     Span // primary location
+	Related  []Related
+	Hint     string  // optional suggestion for fixing the problem
+	Stack    []Frame // for run-time errors, the active calls, outermost first
+}
+
+// Frame is a function call in the stack trace of a run-time error.
+type Frame struct {
+	Function string
+	Args     []string // the argument values, as printed
+	Span     Span     // the call expression
+}
+
+// String returns the call as `name(arg, ...)`.
+func (f Frame) String() string {
+	return f.Function + "(" + strings.Join(f.Args, ", ") + ")"
+}
+
+// Errorf returns an error diagnostic with a formatted message.
+func Errorf(code string, span Span, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+	}
+}
+
+// Error returns the one-line form "pos: message" used by Parser.Errors.
+func (d Diagnostic) Error() string {
+	if !d.Span.Start.IsValid() {
+		return d.Message
+	}
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Message)
+}
+
+// Strings returns the one-line form of each diagnostic.
+func Strings(ds []Diagnostic) []string {
+	out := make([]string, len(ds))
+	for i, d := range ds {
+		out[i] = d.Error()
+	}
+	return out
+}
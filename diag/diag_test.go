@@ -0,0 +1,140 @@
+package diag
+
+import (
+	"GoClang/token"
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func pos(line, col int) token.Position {
+	return token.Position{Filename: "a.gc", Line: line, Column: col}
+}
+
+func TestRender(t *testing.T) {
+	src := "let x = 1;\nlet 名字 = x + true;\n"
+
+	tests := []struct {
+		d        Diagnostic
+		expected string
+	}{
+		{
+			Errorf(ErrUnexpectedToken, Span{pos(1, 7), pos(1, 8)}, "expected next token to be =; got = instead"),
+			`error[E0010]: expected next token to be =; got = instead
+ --> a.gc:1:7
+  |
+1 | let x = 1;
+  |       ^
+`,
+		},
+		{
+			Diagnostic{
+				Severity: Error,
+				Code:     ErrTypeMismatch,
+				Message:  "type mismatch: INTEGER + BOOLEAN",
+				Span:     Span{pos(2, 10), pos(2, 18)},
+				Related:  []Related{{Span{pos(1, 5), pos(1, 6)}, "x declared here"}},
+				Hint:     "convert one side first",
+			},
+			`error[E0102]: type mismatch: INTEGER + BOOLEAN
+ --> a.gc:2:10
+  |
+2 | let 名字 = x + true;
+  |            ^^^^^^^^
+ ::: a.gc:1:5
+  |
+1 | let x = 1;
+  |     - x declared here
+  = hint: convert one side first
+`,
+		},
+		{
+			// a span past the end of the line still gets one caret
+			Errorf(ErrUnexpectedToken, Span{pos(1, 11), pos(1, 11)}, "expected }"),
+			`error[E0010]: expected }
+ --> a.gc:1:11
+  |
+1 | let x = 1;
+  |           ^
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := Render(&out, src, tt.d); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("wrong rendering.\nexpected:\n%s\ngot:\n%s", tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderStack(t *testing.T) {
+	src := "let f = fn(n) { f(n - 1) };\nf(20)\n"
+
+	d := Errorf(ErrTypeMismatch, Span{pos(1, 17), pos(1, 25)}, "boom")
+	for i := 0; i < 25; i++ {
+		span := Span{pos(1, 17), pos(1, 25)}
+		if i == 0 {
+			span = Span{pos(2, 1), pos(2, 6)}
+		}
+		d.Stack = append(d.Stack, Frame{Function: "f", Args: []string{strconv.Itoa(20 - i)}, Span: span})
+	}
+
+	var out bytes.Buffer
+	Render(&out, src, d)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+
+	expected := []string{
+		"  = in f(-4), called at a.gc:1:17",
+		"  = in f(-3), called at a.gc:1:17",
+	}
+	if got := lines[5:7]; strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong innermost calls. expected=%q, got=%q", expected, got)
+	}
+	if got := lines[15]; got != "  = ... 5 more calls ..." {
+		t.Errorf("long stack not shortened. got=%q", got)
+	}
+	if got := lines[len(lines)-1]; got != "  = in f(20), called at a.gc:2:1" {
+		t.Errorf("wrong outermost call. got=%q", got)
+	}
+	if len(lines) != 5+2*maxFrames+1 {
+		t.Errorf("wrong number of lines. got=%d\n%s", len(lines), out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	d := Errorf(ErrUndefined, Span{pos(1, 1), pos(1, 4)}, "identifier not found: %s", "foo")
+	d.Hint = "check the spelling"
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, []Diagnostic{d}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"severity":"error","code":"E0100","message":"identifier not found: foo",` +
+		`"span":{"file":"a.gc","start":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":4,"offset":0}},` +
+		`"hint":"check the spelling"}]`
+	if strings.TrimSpace(out.String()) != expected {
+		t.Errorf("wrong JSON.\nexpected=%s\ngot=%s", expected, out.String())
+	}
+
+	d.Hint = ""
+	d.Stack = []Frame{{Function: "f", Args: []string{`"x"`}, Span: Span{pos(2, 1), pos(2, 7)}}}
+	out.Reset()
+	WriteJSON(&out, []Diagnostic{d})
+	frame := `"stack":[{"function":"f","args":["\"x\""],` +
+		`"span":{"file":"a.gc","start":{"line":2,"column":1,"offset":0},"end":{"line":2,"column":7,"offset":0}}}]`
+	if !strings.Contains(out.String(), frame) {
+		t.Errorf("stack missing from JSON.\nexpected=%s\ngot=%s", frame, out.String())
+	}
+
+	out.Reset()
+	WriteJSON(&out, nil)
+	if strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("expected empty array. got=%s", out.String())
+	}
+}
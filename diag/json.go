@@ -0,0 +1,80 @@
+package diag
+
+import (
+	"GoClang/token"
+	"encoding/json"
+	"io"
+)
+
+// position is the JSON form of a token.Position.
+type position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+func jsonPosition(p token.Position) position {
+	return position{Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
+
+type jsonSpan struct {
+	File  string   `json:"file,omitempty"`
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type jsonRelated struct {
+	Span    jsonSpan `json:"span"`
+	Message string   `json:"message"`
+}
+
+type jsonDiagnostic struct {
+	Severity string        `json:"severity"`
+	Code     string        `json:"code,omitempty"`
+	Message  string        `json:"message"`
+	Span     jsonSpan      `json:"span"`
+	Related  []jsonRelated `json:"related,omitempty"`
+	Hint     string        `json:"hint,omitempty"`
+	Stack    []jsonFrame   `json:"stack,omitempty"`
+}
+
+type jsonFrame struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+	Span     jsonSpan `json:"span"`
+}
+
+func toJSONSpan(s Span) jsonSpan {
+	return jsonSpan{File: s.Start.Filename, Start: jsonPosition(s.Start), End: jsonPosition(s.End)}
+}
+
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	out := jsonDiagnostic{
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+		Span:     toJSONSpan(d.Span),
+		Hint:     d.Hint,
+	}
+	for _, r := range d.Related {
+		out.Related = append(out.Related, jsonRelated{Span: toJSONSpan(r.Span), Message: r.Message})
+	}
+	for _, f := range d.Stack {
+		args := f.Args
+		if args == nil {
+			args = []string{}
+		}
+		out.Stack = append(out.Stack, jsonFrame{Function: f.Function, Args: args, Span: toJSONSpan(f.Span)})
+	}
+	return json.Marshal(out)
+}
+
+// WriteJSON writes ds as a single JSON array followed by a newline, for CI
+// jobs and editor integrations. Lines and columns are 1-based, offsets are
+// 0-based byte offsets, and span ends are exclusive.
+func WriteJSON(w io.Writer, ds []Diagnostic) error {
+	if ds == nil {
+		ds = []Diagnostic{}
+	}
+	return json.NewEncoder(w).Encode(ds)
+}
@@ -0,0 +1,150 @@
+package diag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Render writes d for a person to read:
+//
+//	error[E0010]: expected next token to be =; got INT instead
+//	 --> script.gc:1:7
+//	  |
+//	1 | let x 5;
+//	  |       ^
+//	  = hint: ...
+//	  = in add(1, "x"), called at script.gc:7:10
+//
+// The calls in the stack trace of a run-time error are listed innermost
+// first. src is the text the spans refer to. Source lines are only shown for spans
+// that fall inside src.
+func Render(w io.Writer, src string, d Diagnostic) error {
+	lines := strings.Split(src, "\n")
+
+	width := len(strconv.Itoa(d.Span.Start.Line))
+	for _, r := range d.Related {
+		if n := len(strconv.Itoa(r.Span.Start.Line)); n > width {
+			width = n
+		}
+	}
+	gutter := strings.Repeat(" ", width)
+
+	var buf bytes.Buffer
+	buf.WriteString(d.Severity.String())
+	if d.Code != "" {
+		fmt.Fprintf(&buf, "[%s]", d.Code)
+	}
+	fmt.Fprintf(&buf, ": %s\n", d.Message)
+
+	if d.Span.Start.IsValid() {
+		fmt.Fprintf(&buf, "%s--> %s\n", gutter, d.Span.Start)
+		writeSnippet(&buf, lines, gutter, d.Span, '^', "")
+	}
+	for _, r := range d.Related {
+		if !r.Span.Start.IsValid() {
+			fmt.Fprintf(&buf, "%s = note: %s\n", gutter, r.Message)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s::: %s\n", gutter, r.Span.Start)
+		writeSnippet(&buf, lines, gutter, r.Span, '-', r.Message)
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(&buf, "%s = hint: %s\n", gutter, d.Hint)
+	}
+	writeStack(&buf, gutter, d.Stack)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// RenderAll renders each diagnostic in turn, separated by blank lines.
+func RenderAll(w io.Writer, src string, ds []Diagnostic) error {
+	for i, d := range ds {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if err := Render(w, src, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxFrames is the number of calls shown from each end of a long stack
+// trace, such as one left by runaway recursion.
+const maxFrames = 10
+
+// writeStack writes the calls in stack, innermost first, leaving out the
+// middle of long stacks.
+func writeStack(buf *bytes.Buffer, gutter string, stack []Frame) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if i == len(stack)-1-maxFrames && i >= maxFrames {
+			fmt.Fprintf(buf, "%s = ... %d more calls ...\n", gutter, i-maxFrames+1)
+			i = maxFrames
+			continue
+		}
+		fmt.Fprintf(buf, "%s = in %s, called at %s\n", gutter, stack[i], stack[i].Span.Start)
+	}
+}
+
+// writeSnippet writes the first source line of span with marker characters
+// under the spanned text, followed by label.
+func writeSnippet(buf *bytes.Buffer, lines []string, gutter string, span Span, marker rune, label string) {
+	if span.Start.Line > len(lines) {
+		return
+	}
+	line := []rune(strings.TrimSuffix(lines[span.Start.Line-1], "\r"))
+
+	from := span.Start.Column - 1
+	if from > len(line) {
+		from = len(line)
+	}
+	to := len(line)
+	if span.End.Line == span.Start.Line && span.End.Column-1 < to {
+		to = span.End.Column - 1
+	}
+
+	var pad, marks strings.Builder
+	for _, r := range line[:from] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+	n := 0
+	for i := from; i < to; i++ {
+		n += runeWidth(line[i])
+	}
+	if n == 0 {
+		n = 1
+	}
+	marks.WriteString(strings.Repeat(string(marker), n))
+	if label != "" {
+		marks.WriteString(" " + label)
+	}
+
+	fmt.Fprintf(buf, "%s |\n", gutter)
+	fmt.Fprintf(buf, "%*d | %s\n", len(gutter), span.Start.Line, string(line))
+	fmt.Fprintf(buf, "%s | %s%s\n", gutter, pad.String(), marks.String())
+}
+
+// runeWidth returns the number of terminal columns r usually occupies.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana),
+		r >= 0x3000 && r <= 0x303f, // CJK punctuation
+		r >= 0xff00 && r <= 0xff60, // fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6:
+		return 2
+	}
+	return 1
+}
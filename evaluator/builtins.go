@@ -1,33 +1,36 @@
 package evaluator
 
 import (
+	"GoClang/diag"
 	"GoClang/object"
 	"fmt"
+	"sort"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{Fn: func(args ...object.Object) object.Object {
 		if len(args) != 1 {
-			return newError("wrong number of arguments. got=%d, want=1", len(args))
+			return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 		}
 
 		switch arg := args[0].(type) {
 		case *object.String:
-			return &object.Integer{Value: int64(len(arg.Value))}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 		case *object.Array:
 			return &object.Integer{Value: int64(len(arg.Elements))}
 
 		default:
-			return newError("argument to `len` not support, got=%s", args[0].Type())
+			return newError(diag.ErrArgumentType, "argument to `len` not support, got=%s", args[0].Type())
 		}
 	}},
 	"first":&object.Builtin{Fn: func(args ...object.Object) object.Object {
 		if len(args) != 1 {
-			return newError("wrong number of arguments. got=%d, want=1", len(args))
+			return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 		}
 
 		if args[0].Type() != object.ARRAY_OBJ {
-			return newError("arguments to 'first' must be ARRAY, got %s", args[0].Type())
+			return newError(diag.ErrArgumentType, "arguments to 'first' must be ARRAY, got %s", args[0].Type())
 		}
 
 		arr := args[0].(*object.Array)
@@ -39,11 +42,11 @@ var builtins = map[string]*object.Builtin{
 
 	"last":&object.Builtin{Fn: func(args ...object.Object) object.Object {
 		if len(args) != 1 {
-			return newError("wrong number of arguments. got=%d, want=1", len(args))
+			return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 		}
 
 		if args[0].Type() != object.ARRAY_OBJ {
-			return newError("arguments to 'first' must be ARRAY, got %s", args[0].Type())
+			return newError(diag.ErrArgumentType, "arguments to 'first' must be ARRAY, got %s", args[0].Type())
 		}
 
 		arr := args[0].(*object.Array)
@@ -56,11 +59,11 @@ var builtins = map[string]*object.Builtin{
 
 	"rest":&object.Builtin{Fn: func(args ...object.Object) object.Object {
 		if len(args) != 1 {
-			return newError("wrong number of arguments. got=%d, want=1", len(args))
+			return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 		}
 
 		if args[0].Type() != object.ARRAY_OBJ {
-			return newError("arguments to 'first' must be ARRAY, got %s", args[0].Type())
+			return newError(diag.ErrArgumentType, "arguments to 'first' must be ARRAY, got %s", args[0].Type())
 		}
 
 		arr := args[0].(*object.Array)
@@ -76,11 +79,11 @@ var builtins = map[string]*object.Builtin{
 
 	"push":&object.Builtin{Fn: func(args ...object.Object) object.Object {
 		if len(args) != 2 {
-			return newError("wrong number of arguments. got=%d, want=2", len(args))
+			return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want=2", len(args))
 		}
 
 		if args[0].Type() != object.ARRAY_OBJ {
-			return newError("arguments to 'first' must be ARRAY, got %s", args[0].Type())
+			return newError(diag.ErrArgumentType, "arguments to 'first' must be ARRAY, got %s", args[0].Type())
 		}
 
 		arr := args[0].(*object.Array)
@@ -100,3 +103,13 @@ var builtins = map[string]*object.Builtin{
 		return NULL
 	}},
 }
+
+// BuiltinNames returns the names of the built-in functions in sorted order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
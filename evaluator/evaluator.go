@@ -2,40 +2,201 @@ package evaluator
 
 import (
 	"GoClang/ast"
+	"GoClang/diag"
 	"GoClang/object"
+	"context"
 	"fmt"
+	"math"
+	"strings"
+	"time"
 )
 
 var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// MaxCallDepth is the number of function calls that may be active at once.
+// A call beyond it fails with "maximum recursion depth exceeded" rather than
+// overflowing the Go stack, which would crash the host process.
+//
+// A call in tail position replaces its caller and does not count, so a loop
+// written as tail recursion may run for any number of iterations. Like a
+// while loop, runaway tail recursion is stopped by the limits of
+// EvalWithOptions.
+var MaxCallDepth = 10000
+
+// Eval evaluates node in env. Errors raised while evaluating node are tagged
+// with the position of the innermost node that produced them, and with the
+// calls that were active when they were raised.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalWithOptions(context.Background(), node, env, Options{})
+}
+
+// Options limits the resources an evaluation may use, for running programs
+// that are not trusted. The zero value sets no limits beyond MaxCallDepth.
+type Options struct {
+	MaxSteps     int           // nodes that may be evaluated, 0 for no limit
+	Timeout      time.Duration // wall-clock time allowed, 0 for no limit
+	MaxCallDepth int           // 0 uses MaxCallDepth
+}
+
+// EvalWithOptions is like Eval, but stops with an error when ctx is done or
+// a limit in opts is reached. The limits are checked before each node is
+// evaluated, so a builtin that is already running is not interrupted.
+func EvalWithOptions(ctx context.Context, node ast.Node, env *object.Environment, opts Options) object.Object {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	in := &interpreter{
+		done:         ctx.Done(),
+		ctx:          ctx,
+		maxSteps:     opts.MaxSteps,
+		maxCallDepth: opts.MaxCallDepth,
+	}
+	if in.maxCallDepth <= 0 {
+		in.maxCallDepth = MaxCallDepth
+	}
+	return in.eval(node, env)
+}
+
+// interpreter holds the state of one evaluation.
+type interpreter struct {
+	frames       []object.Frame // active calls, outermost first
+	maxCallDepth int
+
+	ctx      context.Context
+	done     <-chan struct{} // ctx.Done(), nil if ctx cannot be canceled
+	steps    int
+	maxSteps int
+
+	// halted is the error that stopped the evaluation when a limit was
+	// reached. It is returned for every node evaluated afterwards.
+	halted *object.Error
+}
+
+func (in *interpreter) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := in.checkLimits(); err != nil {
+		return positioned(node, err)
+	}
+	return positioned(node, in.evalNode(node, env))
+}
+
+// checkLimits counts a step and returns an error once the evaluation has
+// been canceled or has run out of steps or time.
+func (in *interpreter) checkLimits() *object.Error {
+	if in.halted != nil {
+		return in.halted
+	}
+
+	in.steps++
+	if in.maxSteps > 0 && in.steps > in.maxSteps {
+		in.halted = newError(diag.ErrStepLimit, "step limit exceeded (%d steps)", in.maxSteps)
+		return in.halted
+	}
+
+	select {
+	case <-in.done:
+	default:
+		return nil
+	}
+	if in.ctx.Err() == context.DeadlineExceeded {
+		in.halted = newError(diag.ErrTimeout, "evaluation timed out")
+	} else {
+		in.halted = newError(diag.ErrCanceled, "evaluation canceled")
+	}
+	return in.halted
+}
+
+// positioned tags result with the position of node if it is an error that
+// has none yet.
+func positioned(node ast.Node, result object.Object) object.Object {
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+		err.End = node.End()
+	}
+	return result
+}
+
+// tailCall is a call in tail position that has not been made yet. It is
+// returned to applyFunction, which makes it in place of the call it is
+// running.
+type tailCall struct {
+	call *ast.CallExpression
+	fn   *object.Function
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call of " + tc.fn.DisplayName() }
+
+// evalTail evaluates node in tail position of a function body: the last
+// statement of the body, a branch of an if in tail position, or the value of
+// a return in tail position. Calls of functions there become tail calls, so
+// that recursion in tail position runs in constant Go stack. Only
+// applyFunction evaluates a body this way, and it makes the tail calls.
+func (in *interpreter) evalTail(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		result = in.evalBlockStatements(node, env, true)
+	case *ast.ExpressionStatement:
+		result = in.evalTail(node.Expression, env)
+	case *ast.IfExpression:
+		result = in.evalIfExpression(node, env, true)
+	case *ast.CallExpression:
+		result = in.evalCallExpression(node, env, true)
+	case *ast.ReturnStatement:
+		value := in.evalTail(node.ReturnValue, env)
+		if isError(value) {
+			result = value
+		} else {
+			result = &object.ReturnValue{Value: value}
+		}
+	default:
+		return in.eval(node, env)
+	}
+	return positioned(node, result)
+}
+
+func (in *interpreter) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return in.evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return in.eval(node.Expression, env)
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := in.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		right := Eval(node.Right, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return in.evalLogicalExpression(node, env)
+		}
+
+		left := in.eval(node.Left, env)
+		right := in.eval(node.Right, env)
 		if isError(left) {
 			return left
 		}
@@ -46,20 +207,32 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalInfixExpression(node.Operator, left, right)
 
 	case *ast.BlockStatement:
-		return evalBlockStatements(node, env)
+		return in.evalBlockStatements(node, env, false)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return in.evalIfExpression(node, env, false)
+
+	case *ast.WhileStatement:
+		return in.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return in.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
 
 	case *ast.ReturnStatement:
-		value := Eval(node.ReturnValue, env)
+		value := in.eval(node.ReturnValue, env)
 		if isError(value) {
 			return value
 		}
 		return &object.ReturnValue{Value: value}
 
 	case *ast.LetStatement:
-		value := Eval(node.Value, env)
+		value := in.eval(node.Value, env)
 		if isError(value) {
 			return value
 		}
@@ -68,56 +241,53 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
+	case *ast.AssignExpression:
+		return in.evalAssignExpression(node, env)
+
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body, Name: node.Name}
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-
-		return applyFunction(function, args)
+		return in.evalCallExpression(node, env, false)
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := in.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := in.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(node.Index, env)
+		right := in.eval(node.Index, env)
 		if isError(right) {
 			return right
 		}
 		return evalIndexExpression(left, right)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return in.evalHashLiteral(node, env)
+
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError(diag.ErrSyntaxErrors, "cannot evaluate code with syntax errors")
 	}
 
 	return nil
 }
-func evalProgram(node *ast.Program, env *object.Environment) object.Object {
+func (in *interpreter) evalProgram(node *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range node.Statements {
-		result = Eval(statement, env)
+		result = in.eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -129,11 +299,11 @@ func evalProgram(node *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+func (in *interpreter) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = in.eval(statement, env)
 
 		if returnValue, ok := result.(*object.ReturnValue); ok {
 			return returnValue.Value
@@ -142,19 +312,101 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 	return result
 }
 
-func evalBlockStatements(node *ast.BlockStatement, env *object.Environment) object.Object {
+// evalBlockStatements evaluates a block. When tail is set the block is in
+// tail position, and so is its last statement.
+func (in *interpreter) evalBlockStatements(node *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
 	var result object.Object
 
-	for _, statement := range node.Statements {
-		result = Eval(statement, env)
+	for i, statement := range node.Statements {
+		if tail && i == len(node.Statements)-1 {
+			result = in.evalTail(statement, env)
+		} else {
+			result = in.eval(statement, env)
+		}
 
-		if result != NULL && (result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ) {
-			return result
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
 		}
 	}
 	return result
 }
 
+func (in *interpreter) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := in.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := in.eval(node.Body, env)
+		if stop, value := loopControl(result); stop {
+			return value
+		}
+	}
+}
+
+// evalForStatement runs the body once per array element, hash key or string
+// code point. Each iteration binds the loop variable in a fresh enclosed
+// environment, so closures created in the body capture that iteration's value.
+func (in *interpreter) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := in.eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	if iterable == nil {
+		iterable = NULL
+	}
+
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = append(items, iterable.Elements...)
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			items = append(items, pair.Key)
+		}
+	case *object.String:
+		for _, r := range iterable.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	default:
+		return newError(diag.ErrNotIterable, "cannot iterate over %s", iterable.Type())
+	}
+
+	for _, item := range items {
+		loopEnv := object.NewClosedEnvironments(env)
+		loopEnv.Set(node.Variable.Value, item)
+
+		result := in.eval(node.Body, loopEnv)
+		if stop, value := loopControl(result); stop {
+			return value
+		}
+	}
+	return NULL
+}
+
+// loopControl interprets the result of one loop iteration. It reports whether
+// the loop must stop and, if so, the value the loop statement evaluates to.
+func loopControl(result object.Object) (bool, object.Object) {
+	if result == nil {
+		return false, nil
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return true, NULL
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return true, result
+	}
+	return false, nil
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -162,11 +414,11 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (in *interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, e := range exps {
-		evaluated := Eval(e, env)
+		evaluated := in.eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -175,14 +427,54 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+// evalArguments evaluates call arguments, passing the elements of a spread
+// array as separate arguments.
+func (in *interpreter) evalArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok {
+			evaluated := in.eval(e, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			result = append(result, evaluated)
+			continue
+		}
+
+		evaluated := in.eval(spread.Value, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			err := newError(diag.ErrNotSpreadable, "cannot spread %s", evaluated.Type())
+			err.Pos, err.End = spread.Pos(), spread.End()
+			return []object.Object{err}
+		}
+		result = append(result, array.Elements...)
+	}
+	return result
+}
+
 func evalPrefixExpression(operator string, obj object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(obj)
 	case "-":
 		return evalMinusOperatorExpression(obj)
+	case "~":
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return newError(diag.ErrUnknownOperator, "unknown operator: ~%s", obj.Type())
+		}
+		return &object.Integer{Value: ^integer.Value}
 	default:
-		return newError("unknown operator: %s%s", operator, obj.Type())
+		return newError(diag.ErrUnknownOperator, "unknown operator: %s%s", operator, obj.Type())
 	}
 }
 
@@ -190,16 +482,18 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.ErrTypeMismatch, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.ErrUnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -217,11 +511,14 @@ func evalBangOperatorExpression(obj object.Object) object.Object {
 }
 
 func evalMinusOperatorExpression(obj object.Object) object.Object {
-	result, ok := obj.(*object.Integer)
-	if !ok {
-		return newError("unknown operator: -%s", obj.Type())
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -obj.Value}
+	case *object.Float:
+		return &object.Float{Value: -obj.Value}
+	default:
+		return newError(diag.ErrUnknownOperator, "unknown operator: -%s", obj.Type())
 	}
-	return &object.Integer{Value: -result.Value}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -237,41 +534,195 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return &object.Integer{Value: leftValue * rightValue}
 	case "/":
 		if rightValue == 0 {
-			return newError("Dividend=0 illegal!")
+			return newError(diag.ErrDivisionByZero, "Dividend=0 illegal!")
 		}
 		return &object.Integer{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError(diag.ErrDivisionByZero, "Dividend=0 illegal!")
+		}
+		return &object.Integer{Value: leftValue % rightValue}
+	case "**":
+		// a negative exponent gives a fraction, so the result is a FLOAT
+		if rightValue < 0 {
+			if leftValue == 0 {
+				return newError(diag.ErrDivisionByZero, "Dividend=0 illegal!")
+			}
+			return &object.Float{Value: math.Pow(float64(leftValue), float64(rightValue))}
+		}
+		return &object.Integer{Value: integerPower(leftValue, rightValue)}
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<":
+		if rightValue < 0 {
+			return newError(diag.ErrNegativeShift, "negative shift count: %d", rightValue)
+		}
+		return &object.Integer{Value: leftValue << uint64(rightValue)}
+	case ">>":
+		if rightValue < 0 {
+			return newError(diag.ErrNegativeShift, "negative shift count: %d", rightValue)
+		}
+		return &object.Integer{Value: leftValue >> uint64(rightValue)}
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 	case ">":
 		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
 		return nativeBoolToBooleanObject(leftValue != rightValue)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.ErrUnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts an INTEGER or FLOAT object to a float64.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
 	}
+	return obj.(*object.Float).Value
+}
 
+// evalFloatInfixExpression handles arithmetic where at least one operand is
+// a FLOAT; an INTEGER operand is promoted to FLOAT first.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError(diag.ErrDivisionByZero, "Dividend=0 illegal!")
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError(diag.ErrDivisionByZero, "Dividend=0 illegal!")
+		}
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		if leftValue == 0 && rightValue < 0 {
+			return newError(diag.ErrDivisionByZero, "Dividend=0 illegal!")
+		}
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError(diag.ErrUnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// integerPower computes base ** exp for exp >= 0 by repeated squaring.
+// Like the other integer operators it wraps around on overflow.
+func integerPower(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// evalStringInfixExpression concatenates strings with + and compares them
+// byte-wise, which for UTF-8 is the same as comparing code points.
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
-	return &object.String{Value: leftValue + rightValue}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	default:
+		return newError(diag.ErrUnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
-func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(node.Condition, env)
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated when the left one does not already decide the result, and the
+// result is always a BOOLEAN based on the operands' truthiness.
+func (in *interpreter) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := in.eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := in.eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func (in *interpreter) evalIfExpression(node *ast.IfExpression, env *object.Environment, tail bool) object.Object {
+	condition := in.eval(node.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	var branch *ast.BlockStatement
 	if isTruthy(condition) {
-		return Eval(node.Consequence, env)
+		branch = node.Consequence
 	} else if node.Alternative != nil {
-		return Eval(node.Alternative, env)
+		branch = node.Alternative
 	} else {
 		return NULL
 	}
+
+	if tail {
+		return in.evalTail(branch, env)
+	}
+	return in.eval(branch, env)
 }
 
 func isTruthy(condition object.Object) bool {
@@ -287,8 +738,8 @@ func isTruthy(condition object.Object) bool {
 	}
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(code string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Code: code, Message: fmt.Sprintf(format, a...)}
 }
 
 func isError(obj object.Object) bool {
@@ -307,29 +758,216 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("identifier not found: ", node.Value)
+	return newError(diag.ErrUndefined, "identifier not found: %s", node.Value)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalAssignExpression evaluates `name = value`, `left[index] = value` and
+// their compound forms. A compound assignment reads the current value before
+// evaluating the right-hand side. The result is the assigned value.
+func (in *interpreter) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if operator != "" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := in.eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if operator != "" {
+			value = evalInfixExpression(operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		if _, ok := env.Assign(target.Value, value); !ok {
+			err := newError(diag.ErrUndeclaredAssign, "assignment to undeclared variable: %s", target.Value)
+			err.Hint = fmt.Sprintf("declare it first with `let %s = ...`", target.Value)
+			return err
+		}
+		return value
+
+	case *ast.IndexExpression:
+		left := in.eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := in.eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if operator != "" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := in.eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if operator != "" {
+			value = evalInfixExpression(operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		return evalIndexAssignment(left, index, value)
+
+	default:
+		return newError(diag.ErrInvalidAssignment, "invalid assignment target: %s", node.Target.String())
+	}
+}
+
+// evalCallExpression evaluates a call. When tail is set the call is in tail
+// position, and a call of a function is returned as a tailCall instead of
+// being made.
+func (in *interpreter) evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	function := in.eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := in.evalArguments(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if fn, ok := function.(*object.Function); ok && tail {
+		return &tailCall{call: node, fn: fn, args: args}
+	}
+	return in.applyFunction(node, function, args)
+}
+
+// applyFunction calls fn with args for the call expression call. While a
+// function runs, its call is on the interpreter's stack, and errors raised
+// inside it record a copy of that stack.
+//
+// A function body that ends in a tail call is a trampoline: the call
+// replaces the current one, both on the stack and in the Go stack, so that
+// a loop written as tail recursion runs in constant space.
+func (in *interpreter) applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendEnv)
-		return unwrapReturnValue(evaluated)
+		in.frames = append(in.frames, object.Frame{Function: fn, Args: args, Call: call})
+		defer func() { in.frames = in.frames[:len(in.frames)-1] }()
+
+		if len(in.frames) > in.maxCallDepth {
+			return in.traced(recursionError(in.maxCallDepth))
+		}
+
+		for {
+			in.frames[len(in.frames)-1] = object.Frame{Function: fn, Args: args, Call: call}
+
+			extendEnv, err := in.extendFunctionEnv(fn, args)
+			if err != nil {
+				return in.traced(err)
+			}
+			evaluated := unwrapReturnValue(in.evalTail(fn.Body, extendEnv))
+			if err, ok := evaluated.(*object.Error); ok {
+				return in.traced(err)
+			}
+
+			if evaluated == nil {
+				// an empty body, or one ending in a let, has no value
+				return NULL
+			}
+			tc, ok := evaluated.(*tailCall)
+			if !ok {
+				return evaluated
+			}
+			call, fn, args = tc.call, tc.fn, tc.args
+		}
 	case *object.Builtin:
 		return fn.Fn(args...)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(diag.ErrNotCallable, "not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+func recursionError(depth int) *object.Error {
+	err := newError(diag.ErrRecursionDepth, "maximum recursion depth exceeded (%d calls)", depth)
+	err.Hint = "check that the recursion reaches its base case"
+	return err
+}
+
+// traced records the current stack in err, unless it already has the stack
+// of a deeper call.
+func (in *interpreter) traced(err *object.Error) *object.Error {
+	if err.Stack == nil {
+		err.Stack = append([]object.Frame(nil), in.frames...)
+	}
+	return err
+}
+
+// extendFunctionEnv binds the parameters of fn to args. Missing arguments
+// take their defaults, evaluated left to right in the new environment so
+// that they can refer to earlier parameters, and any extra arguments are
+// collected in the rest parameter.
+func (in *interpreter) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+	if err := checkArity(len(args), required, len(fn.Parameters), fn.Rest != nil); err != nil {
+		return nil, err
+	}
+
 	env := object.NewClosedEnvironments(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		value := in.eval(fn.Defaults[paramIdx], env)
+		if err, ok := value.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// checkArity reports a call with got arguments to a function taking between
+// min and max of them, or at least min when it has a rest parameter.
+func checkArity(got, min, max int, rest bool) *object.Error {
+	switch {
+	case rest && got < min:
+		return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want at least %d", got, min)
+	case rest:
+		return nil
+	case got >= min && got <= max:
+		return nil
+	case min == max:
+		return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want=%d", got, min)
+	default:
+		return newError(diag.ErrArgumentCount, "wrong number of arguments. got=%d, want %d to %d", got, min, max)
 	}
-	return env
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -345,11 +983,40 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 		{
 			return evalArrayIndexExpression(left, index)
 		}
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 
 	default:
-		return newError("index operator is not support: %s", left.Type())
+		return newError(diag.ErrNotIndexable, "index operator is not support: %s", left.Type())
+	}
+}
+
+// evalIndexAssignment stores value at left[index], updating the array or
+// hash in place.
+func evalIndexAssignment(left, index, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
+			return newError(diag.ErrIndexOutOfRange, "index out of range: %d (length %d)", idx, len(arrayObject.Elements))
+		}
+		arrayObject.Elements[idx] = value
+		return value
+
+	case left.Type() == object.HASH_OBJ:
+		hashObject := left.(*object.Hash)
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(diag.ErrUnhashable, "unusable as hash key: %s", index.Type())
+		}
+		hashObject.Set(key, value)
+		return value
+
+	default:
+		return newError(diag.ErrNotIndexable, "index assignment not supported: %s", left.Type())
 	}
 }
 
@@ -364,14 +1031,26 @@ func evalArrayIndexExpression(left object.Object, index object.Object) object.Ob
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression indexes a string by code point, not by byte.
+func evalStringIndexExpression(left object.Object, index object.Object) object.Object {
+	runes := []rune(left.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	max := int64(len(runes) - 1)
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
 func evalHashIndexExpression(left object.Object, index object.Object) object.Object {
 	hashObject := left.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(diag.ErrUnhashable, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pair[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
@@ -379,27 +1058,26 @@ func evalHashIndexExpression(left object.Object, index object.Object) object.Obj
 	return pair.Value
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (in *interpreter) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key){
+	for _, pair := range node.Pairs {
+		key := in.eval(pair.Key, env)
+		if isError(key) {
 			return key
 		}
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(diag.ErrUnhashable, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := in.eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key:key, Value:value}
+		hash.Set(hashKey, value)
 	}
-	return &object.Hash{Pair:pairs}
-}
\ No newline at end of file
+	return hash
+}
@@ -4,7 +4,10 @@ import (
 	"GoClang/lexer"
 	"GoClang/object"
 	"GoClang/parser"
+	"context"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -23,6 +26,19 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * (3 * 3) + 10", 37},
 		{"10 / 2 + 3 * 10", 35},
 		{"50 / 2 * 2", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 ** 0", 1},
+		{"0xF0 & 0x3C", 0x30},
+		{"0xF0 | 0x0F", 0xFF},
+		{"0xFF ^ 0x0F", 0xF0},
+		{"~0", -1},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"(12345 * 31) % 16", 7},
 	}
 
 	for _, tt := range tests {
@@ -31,6 +47,33 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"2.5E-1", 0.25},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5},
+		{"7.5 % 2", 1.5},
+		{"2.0 ** 0.5 ** 2", 1.189207115002721},
+		{"2 ** -1", 0.5},
+		{"let x = 2; x **= -1; x", 0.5},
+		{"0.0 ** 0", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -44,6 +87,9 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{" 1 == 1", true},
 		{"1 != 1", false},
 		{"1 == 2", false},
+		{"1 == 1.0", true},
+		{"2.5 > 2", true},
+		{"0.1 + 0.2 != 0.3", true},
 		{"1 != 2", true},
 		{"true == true", true},
 		{"true != false", true},
@@ -54,6 +100,16 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == true", true},
 		{"(1 > 2) == false", true},
 		{"(1 < 2) == false", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"2.5 >= 2.5", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 < 2 && 3 < 2", false},
+		{"false || 1 > 0", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"!true || !false", true},
 	}
 
 	for _, tt := range tests {
@@ -81,6 +137,32 @@ func TestBangOperator(t *testing.T) {
 	}
 }
 
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && undefinedName", false},
+		{"true || undefinedName", true},
+		{"false && (1 + true)", false},
+		{"let f = fn() { 1 + true }; true || f()", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval("true && undefinedName")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: undefinedName" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -138,7 +220,19 @@ func TestErrorHandling(t *testing.T) {
 		{`if(10 > 1){ if(10 > 1){ return true + false; } return 1;}`, "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
 		{`"hello" - "world"`, "unknown operator: STRING - STRING"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"-true * 1.0", "unknown operator: -BOOLEAN"},
+		{"1.0 / 0", "Dividend=0 illegal!"},
+		{"5 % 0", "Dividend=0 illegal!"},
+		{"0 ** -1", "Dividend=0 illegal!"},
+		{"0.0 ** -0.5", "Dividend=0 illegal!"},
+		{"1 << -1", "negative shift count: -1"},
+		{"8 >> -2", "negative shift count: -2"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~true", "unknown operator: ~BOOLEAN"},
 		{`{"name": "Monkey"}[fn(x){ x }];`, "unusable as hash key: FUNCTION"},
+		{"if (1 + true) { 10 } else { 20 }", "type mismatch: INTEGER + BOOLEAN"},
+		{`if (foobar) { "ok" }`, "identifier not found: foobar"},
 	}
 
 	for _, tt := range tests {
@@ -173,6 +267,104 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let a = 1; let b = 2; a = b = 5; a + b", 10},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 3; x *= 2; x", 14},
+		{"let x = 17; x /= 2; x %= 5; x", 3},
+		{"let x = 2; x **= 3; x <<= 1; x", 16},
+		{"let x = 6; x &= 3; x |= 8; x ^= 1; x >>= 1", 5},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn() { let x = 2; x = 3 }; f(); x", 1},
+		{"let newCounter = fn() { let n = 0; fn() { n += 1 } }; let c = newCounter(); c(); c(); c()", 3},
+		{"let a = [1, 2, 3]; a[1] = 20; a[1]", 20},
+		{"let a = [1, 2, 3]; a[0] += 10; a[0]", 11},
+		{"let h = {\"a\": 1}; h[\"b\"] = 2; h[\"a\"] + h[\"b\"]", 3},
+		{"let h = {\"a\": 1}; h[\"a\"] *= 7; h[\"a\"]", 7},
+		{"let m = [[1], [2]]; m[1][0] = 9; m[1][0]", 9},
+		{"y = 1", "assignment to undeclared variable: y"},
+		{"y += 1", "identifier not found: y"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 (length 1)"},
+		{"let a = [1]; a[-1] = 2", "index out of range: -1 (length 1)"},
+		{"let s = \"ab\"; s[0] = \"c\"", "index assignment not supported: STRING"},
+		{"let h = {}; h[fn(){}] = 1", "unusable as hash key: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; while (false) { i = 1 }; i", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break } }; i", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } sum += i }; sum", 25},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { sum += x }; sum", 10},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break } sum += x }; sum", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { continue } sum += x }; sum", 7},
+		{"let n = 0; for (k in {\"a\": 1, \"b\": 2}) { n += 1 }; n", 2},
+		{"let n = 0; for (c in \"中文abc\") { n += 1 }; n", 5},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10 } } 0 }; f()", 20},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i > 3) { return i } } }; f()", 4},
+		{"let n = 0; for (a in [1, 2]) { for (b in [1, 2, 3]) { if (b == 2) { break } n += 1 } }; n", 2},
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }) }; fs[0]() + fs[2]()", 4},
+		{"let big = 0; let i = 0; while (i < 100000) { big += i; i += 1 }; big", 4999950000},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"let e = fn() {}; for (x in e()) {}", "cannot iterate over NULL"},
+		{"let e = fn() { let y = 1; }; for (x in e()) {}", "cannot iterate over NULL"},
+		{"for (x in if (true) {}) {}", "cannot iterate over NULL"},
+		{"let i = 0; while (i < 3) { i += true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"while (undefinedName) { }", "identifier not found: undefinedName"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+
+	testNullObject(t, testEval("let i = 0; while (i < 1) { i += 1 }"))
+	testNullObject(t, testEval("let e = fn() {}; e()"))
+}
+
 func TestFunctionObject(t *testing.T) {
 	tests := "fn(x) { x + 2; };"
 	evaluated := testEval(tests)
@@ -260,6 +452,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("你好，世界")`, 5},
 		{`len(1)`, "argument to `len` not support, got=INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
@@ -330,6 +523,39 @@ func TestArrayIndexExpression(t *testing.T) {
 
 }
 
+func TestStringIndexExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"中文字"[1]`, "文"},
+		{`let 名字 = "张三"; 名字[1]`, "三"},
+		{`"héllo"[1]`, "é"},
+		{`"中文"[2]`, nil},
+		{`"中文"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T)  {
 	input := `let two = "two";
 	{
@@ -347,26 +573,33 @@ func TestHashLiterals(t *testing.T)  {
 		t.Fatalf("Eval didn't return Hash, got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value:"one"}).HashKey() : 1,
-		(&object.String{Value:"two"}).HashKey() : 2,
-		(&object.String{Value:"three"}).HashKey() : 3,
-		(&object.Integer{Value:4}).HashKey() : 4,
-		(&object.Boolean{Value:true}).HashKey() : 5,
-		(&object.Boolean{Value:false}).HashKey() : 6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{&object.Boolean{Value: true}, 5},
+		{&object.Boolean{Value: false}, 6},
 	}
 
-	if len(expected) != len(result.Pair) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pair))
+	if len(expected) != result.Len() {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected{
-		pair, ok := result.Pair[expectedKey]
+	for i, want := range expected {
+		pair, ok := result.Get(want.key)
 		if !ok {
 			t.Error("no pair for given key in Pairs")
 		}
+		testIntegerObject(t, pair.Value, want.value)
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		// pairs keep the order of the literal
+		if got := result.Pairs()[i].Key.(object.Hashable).HashKey(); got != want.key.HashKey() {
+			t.Errorf("pair %d has wrong key. got=%s", i, result.Pairs()[i].Key.Inspect())
+		}
 	}
 }
 
@@ -417,6 +650,20 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -425,7 +672,7 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	}
 
 	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
 		return false
 	}
 	return true
@@ -438,3 +685,486 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "main.gc:1:1"},
+		{"let x = 1;\nlet y = x + foobar;", "main.gc:2:13"},
+		{"let f = fn(a) {\n  a - \"s\"\n};\nf(1)", "main.gc:2:3"},
+		{"len(1, 2)", "main.gc:1:1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("main.gc", tt.input)
+		p := parser.New(l)
+		program := p.ParserProgram()
+		evaluated := Eval(program, object.NewEnviroment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%q, got=%q", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorDiagnostics(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode string
+		expectedSpan string
+	}{
+		{"5 + true;", "E0102", "1:1-1:9"},
+		{"let x = 1;\nlet y = x + foobar;", "E0100", "2:13-2:19"},
+		{"10 / 0", "E0104", "1:1-1:7"},
+		{"y = 3", "E0101", "1:1-1:6"},
+		{"len(1, 2)", "E0107", "1:1-1:10"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		d := errObj.Diagnostic()
+		if d.Code != tt.expectedCode {
+			t.Errorf("wrong code for %q. expected=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		span := d.Span.Start.String() + "-" + d.Span.End.String()
+		if span != tt.expectedSpan {
+			t.Errorf("wrong span for %q. expected=%s, got=%s", tt.input, tt.expectedSpan, span)
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, `{b: 1, a: 2, c: 3}`},
+		{`let h = {"z": 1, "y": 2}; h["x"] = 3; h["z"] = 4; h`, `{z: 4, y: 2, x: 3}`},
+		{`let ks = []; for (k in {3: "c", 1: "a", 2: "b"}) { ks = push(ks, k) }; ks`, `[3,1,2]`},
+		// keys and values are evaluated in source order
+		{`let log = []; let note = fn(x) { log = push(log, x); x };
+		  {note("k1"): note("v1"), note("k2"): note("v2")}; log`, `[k1,v1,k2,v2]`},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Fatalf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`[1, 2] != [1, 2, 3]`, true},
+		{`[] == []`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{} == {}`, true},
+		{`"abc" == "abc"`, true},
+		{`"abc" != "abd"`, true},
+		{`1 == 1.0`, true},
+		{`[1] == [1.0]`, true},
+		{`"1" == 1`, false},
+		{`"true" == true`, false},
+		{`true != "true"`, true},
+		{`[1] == {1: 1}`, false},
+		{`let f = fn() { 1 }; f == f`, true},
+		{`fn() { 1 } == fn() { 1 }`, false},
+		{`len == len`, true},
+		{`let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b`, true},
+		{`let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; a == b`, false},
+		{`let h = {}; h["self"] = h; h == h`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("wrong result for %q", tt.input)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"ab" < "abc"`, true},
+		{`"Z" < "a"`, true},
+		{`"b" >= "b"`, true},
+		{`"a" <= "b"`, true},
+		{`"中" > "a"`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("wrong result for %q", tt.input)
+		}
+	}
+
+	evaluated := testEval(`"a" < 1`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "type mismatch: STRING < INTEGER" {
+		t.Errorf("expected type mismatch for ordering across types. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestDefaultRestAndSpreadArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", "11"},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", "3"},
+		{"let f = fn(a, b = a * 2) { b }; f(4)", "8"},
+		{"let n = 0; let f = fn(a = n) { a }; n = 5; f()", "5"},
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2,3]"},
+		{"let f = fn(a, b = 0, ...rest) { [a, b, rest] }; f(1, 2, 3)", "[1,2,[3]]"},
+		{"let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])", "6"},
+		{"let add = fn(a, b, c) { a + b + c }; add(1, ...[2], ...[], 3)", "6"},
+		{"let f = fn(...xs) { len(xs) }; f(...[1, 2], 3, ...[4])", "4"},
+		{"len(...[[1, 2]])", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	// spreading something without a value is an error, not a crash
+	for _, input := range []string{
+		"let e = fn() {}; let f = fn(...a) { a }; f(...e())",
+		"let f = fn(...a) { a }; f(...if (true) {})",
+	} {
+		evaluated := testEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q. got=%T (%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "cannot spread NULL" {
+			t.Errorf("wrong error message for %q. got=%q", input, errObj.Message)
+		}
+	}
+}
+
+func TestArityErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b) { a }(1)", "wrong number of arguments. got=1, want=2"},
+		{"fn() { 1 }(1)", "wrong number of arguments. got=1, want=0"},
+		{"fn(a, b = 1) { a }()", "wrong number of arguments. got=0, want 1 to 2"},
+		{"fn(a, b = 1) { a }(1, 2, 3)", "wrong number of arguments. got=3, want 1 to 2"},
+		{"fn(a, b, ...rest) { a }(1)", "wrong number of arguments. got=1, want at least 2"},
+		{"fn(a) { a }(...1)", "cannot spread INTEGER"},
+		{"fn(a = b) { a }()", "identifier not found: b"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + true", nil},
+		{
+			"let sub = fn(a, b) { a - b };\nlet twice = fn(x) { let y = sub(x, x); y };\ntwice(\"s\")",
+			[]string{`twice("s") at main.gc:3:1`, `sub("s", "s") at main.gc:2:29`},
+		},
+		// a tail call replaces the call that made it
+		{
+			"let sub = fn(a, b) { a - b };\nlet twice = fn(x) { sub(x, x) };\ntwice(\"s\")",
+			[]string{`sub("s", "s") at main.gc:2:21`},
+		},
+		{
+			"let add = fn(a, b) { a + b };\nadd(1, true)",
+			[]string{"add(1, true) at main.gc:2:1"},
+		},
+		{"fn(a) { a }()", []string{"fn() at main.gc:1:1"}},
+		{"let f = fn() { len(1) };\n[f][0]()", []string{"f() at main.gc:2:1"}},
+		{
+			"let f = fn(n) { n + true };\nif (f(2)) { \"ok\" }",
+			[]string{"f(2) at main.gc:2:5"},
+		},
+		// the trace is of the call that raised the error, not where it is seen
+		{
+			"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { n + true } };\nf(2)",
+			[]string{"f(2) at main.gc:2:1", "f(1) at main.gc:1:34", "f(0) at main.gc:1:34"},
+		},
+		{
+			"let f = fn(n) { if (n > 0) { f(n - 1) } else { n + true } };\nf(2)",
+			[]string{"f(0) at main.gc:1:30"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("main.gc", tt.input)
+		p := parser.New(l)
+		program := p.ParserProgram()
+		evaluated := Eval(program, object.NewEnviroment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		var got []string
+		for _, f := range errObj.Diagnostic().Stack {
+			got = append(got, f.String()+" at "+f.Span.Start.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong stack for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	defer func(depth int) { MaxCallDepth = depth }(MaxCallDepth)
+
+	input := "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };"
+	tests := []struct {
+		depth    int
+		call     string
+		expected interface{}
+	}{
+		{100, "count(99)", 99},
+		{100, "count(100)", "maximum recursion depth exceeded (100 calls)"},
+		// runaway tail recursion does not grow the stack, see TestRunawayTailRecursion
+		{100, "let f = fn() { 1 + f() }; f()", "maximum recursion depth exceeded (100 calls)"},
+		{10000, "count(5000)", 5000},
+		{10000, "let f = fn() { 1 + f() }; f()", "maximum recursion depth exceeded (10000 calls)"},
+		// the error must not be taken for a true condition
+		{100, "let f = fn(n) { if (f(n + 1) == 0) { 0 } else { 1 } }; f(0)", "maximum recursion depth exceeded (100 calls)"},
+	}
+
+	for _, tt := range tests {
+		MaxCallDepth = tt.depth
+		evaluated := testEval(input + tt.call)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.call, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+			if len(errObj.Stack) != tt.depth+1 {
+				t.Errorf("wrong stack depth. expected=%d, got=%d", tt.depth+1, len(errObj.Stack))
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + 1) } }; loop(1000000, 0)", 1000000},
+		{"let loop = fn(n) { if (n == 0) { return 7; } return loop(n - 1); }; loop(1000)", 7},
+		{"let loop = fn(n) { while (true) { if (n == 0) { return 1; } return loop(n - 1); } }; loop(1000)", 1},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(1001)", false},
+		{"let sum = fn(arr, acc) { if (len(arr) == 0) { acc } else { sum(rest(arr), acc + first(arr)) } }; sum([1, 2, 3, 4], 0)", 10},
+		{"let f = fn(n = 3, ...xs) { if (n == 0) { len(xs) } else { f(n - 1, ...xs, n) } }; f()", 3},
+		{"let f = fn(arr) { len(arr) }; f([1, 2])", 2},
+		// not in tail position, so limited by MaxCallDepth
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)", "maximum recursion depth exceeded (10000 calls)"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { let r = f(n - 1); r } }; f(100000)", "maximum recursion depth exceeded (10000 calls)"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { [f(n - 1)] } }; f(100000)", "maximum recursion depth exceeded (10000 calls)"},
+		// a return inside an expression is not in tail position either
+		{"let g = fn() { 5 }; let f = fn() { [if (true) { return g() }] }; f()", "[5]"},
+		{"let g = fn() { 5 }; let f = fn() { push([], if (true) { return g() }) }; f()", "[5]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong value for %q. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestRunawayTailRecursion(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  Options
+	}{
+		{"let f = fn() { f() }; f()", Options{MaxSteps: 100000}},
+		{"let f = fn(n) { f(n + 1) }; f(0)", Options{MaxSteps: 100000}},
+		{"let f = fn(n) { if (n < 0) { 0 } else { f(n + 1) } }; f(0)", Options{Timeout: 10 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParserProgram()
+		evaluated := EvalWithOptions(context.Background(), program, object.NewEnviroment(), tt.opts)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Code != "E0116" && errObj.Code != "E0117" {
+			t.Errorf("expected a step or time limit error for %q. got=%s %q", tt.input, errObj.Code, errObj.Message)
+		}
+		if len(errObj.Stack) != 1 {
+			t.Errorf("tail calls grew the stack for %q. got=%d frames", tt.input, len(errObj.Stack))
+		}
+	}
+}
+
+func TestEvalWithOptions(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input        string
+		ctx          context.Context
+		opts         Options
+		expected     interface{}
+		expectedCode string
+	}{
+		{"let x = 0; while (x < 10) { x += 1 }; x", context.Background(), Options{MaxSteps: 1000}, 10, ""},
+		{"let x = 0; while (true) { x += 1 }", context.Background(), Options{MaxSteps: 1000}, "step limit exceeded (1000 steps)", "E0116"},
+		{"let f = fn() { f() }; f()", context.Background(), Options{Timeout: 10 * time.Millisecond}, "evaluation timed out", "E0117"},
+		{"while (true) {}", context.Background(), Options{Timeout: 10 * time.Millisecond}, "evaluation timed out", "E0117"},
+		{"1 + 1", canceled, Options{}, "evaluation canceled", "E0118"},
+		{"let f = fn(n) { 1 + f(n) }; f(1)", context.Background(), Options{MaxCallDepth: 50}, "maximum recursion depth exceeded (50 calls)", "E0115"},
+		// errors in a condition propagate without a step limit to stop them
+		{"let f = fn() { if (f()) { 1 } else { 2 } }; f()", context.Background(), Options{MaxCallDepth: 100}, "maximum recursion depth exceeded (100 calls)", "E0115"},
+		{"let f = fn() { if (1 + true) { f() } else { 2 } }; f()", context.Background(), Options{}, "type mismatch: INTEGER + BOOLEAN", "E0102"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParserProgram()
+		evaluated := EvalWithOptions(tt.ctx, program, object.NewEnviroment(), tt.opts)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected || errObj.Code != tt.expectedCode {
+				t.Errorf("wrong error for %q. expected=%s %q, got=%s %q",
+					tt.input, tt.expectedCode, expected, errObj.Code, errObj.Message)
+			}
+			if !errObj.Pos.IsValid() {
+				t.Errorf("error for %q has no position", tt.input)
+			}
+		}
+	}
+}
+
+func TestEvalWithOptionsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	program := parser.New(lexer.New("let loop = fn(n) { loop(n + 1) }; loop(0)")).ParserProgram()
+
+	result := make(chan object.Object)
+	go func() { result <- EvalWithOptions(ctx, program, object.NewEnviroment(), Options{}) }()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case evaluated := <-result:
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || errObj.Message != "evaluation canceled" {
+			t.Fatalf("expected a cancellation error. got=%T (%+v)", evaluated, evaluated)
+		}
+		if len(errObj.Stack) != 1 || errObj.Stack[0].Function.Name != "loop" {
+			t.Errorf("wrong stack for cancellation error: %+v", errObj.Stack)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("evaluation was not canceled")
+	}
+}
+
+func TestInspectSelfReference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; a[0] = a; a`, `[[...]]`},
+		{`let a = [1, 2]; a[1] = a; [a]`, `[[1,[...]]]`},
+		{`let h = {"a": 1}; h["self"] = h; h`, `{a: 1, self: {...}}`},
+		{`let a = [1]; let h = {"a": a}; a[0] = h; a`, `[{a: [...]}]`},
+		// a value that appears twice without containing itself prints in full
+		{`let a = [1]; [a, a]`, `[[1],[1]]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if got := evaluated.Inspect(); got != tt.expected {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
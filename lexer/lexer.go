@@ -1,39 +1,108 @@
 package lexer
 
 import (
+	"GoClang/diag"
 	"GoClang/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Lexer reads its input as UTF-8. position and readPosition are byte offsets,
+// while token columns count runes.
 type Lexer struct {
 	input        string
-	position     int
-	readPosition int
-	ch           byte
+	position     int  // byte offset of ch
+	readPosition int  // byte offset of the rune after ch
+	ch           rune // current rune, 0 at end of input
+
+	filename string
+	line     int
+	column   int
+
+	diagnostics []diag.Diagnostic
 }
 
 func New(newInput string) *Lexer {
-	l := &Lexer{input: newInput}
+	return NewFile("", newInput)
+}
+
+// NewFile returns a Lexer whose token positions are reported against filename.
+// A "#!" line at the very start of the input is skipped so that scripts can
+// be made executable.
+func NewFile(filename string, newInput string) *Lexer {
+	l := &Lexer{input: newInput, filename: filename, line: 1}
 	l.readChar()
+	if strings.HasPrefix(newInput, "#!") {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	}
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		// already at the end of the input
+		return
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.position = l.readPosition
 	if l.readPosition == len(l.input) {
 		l.ch = 0
+		l.readPosition++
 	} else {
-		l.ch = l.input[l.readPosition]
+		r, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		l.ch = r
+		l.readPosition += width
 	}
-	l.position = l.readPosition
-	l.readPosition += 1
+	l.column++
+}
+
+// Errors returns the errors found so far, each prefixed with its position.
+func (l *Lexer) Errors() []string {
+	return diag.Strings(l.diagnostics)
+}
+
+// Diagnostics returns the problems found so far.
+func (l *Lexer) Diagnostics() []diag.Diagnostic {
+	return l.diagnostics
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) errorAt(code string, span diag.Span, format string, a ...interface{}) *diag.Diagnostic {
+	l.diagnostics = append(l.diagnostics, diag.Errorf(code, span, format, a...))
+	return &l.diagnostics[len(l.diagnostics)-1]
+}
+
+// spanFrom returns the span from pos up to the current character.
+func (l *Lexer) spanFrom(pos token.Position) diag.Span {
+	return diag.Span{Start: pos, End: l.pos()}
+}
+
+// spanThrough returns the span from pos up to and including the current
+// character.
+func (l *Lexer) spanThrough(pos token.Position) diag.Span {
+	end := l.pos()
+	end.Offset = l.readPosition
+	end.Column++
+	return diag.Span{Start: pos, End: end}
+}
+
+// pos returns the position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{Filename: l.filename, Offset: l.position, Line: l.line, Column: l.column}
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
-
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 func (l *Lexer) skipWhiteSpace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
@@ -42,16 +111,83 @@ func (l *Lexer) skipWhiteSpace() {
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	leading := l.readLeadingComments()
 
-	l.skipWhiteSpace()
+	pos := l.pos()
+	tok := l.nextToken()
+	tok.Pos = pos
+	tok.End = l.pos()
+	tok.Leading = leading
+	if tok.Type != token.EOF {
+		tok.Trailing = l.readTrailingComments()
+	}
+	return tok
+}
+
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// readLeadingComments skips white space and collects every comment up to the
+// start of the next token.
+func (l *Lexer) readLeadingComments() []token.Comment {
+	var comments []token.Comment
+	for {
+		l.skipWhiteSpace()
+		if !l.atComment() {
+			return comments
+		}
+		comments = append(comments, l.readComment())
+	}
+}
+
+// readTrailingComments collects the comments that start on the current line,
+// stopping at the first newline outside a comment.
+func (l *Lexer) readTrailingComments() []token.Comment {
+	var comments []token.Comment
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+			l.readChar()
+		}
+		if !l.atComment() {
+			return comments
+		}
+		comments = append(comments, l.readComment())
+	}
+}
+
+func (l *Lexer) readComment() token.Comment {
+	pos := l.pos()
+	start := l.position
+
+	l.readChar()
+	if l.ch == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	} else {
+		l.readChar()
+		for l.ch != 0 && !(l.ch == '*' && l.peekChar() == '/') {
+			l.readChar()
+		}
+		if l.ch != 0 {
+			l.readChar()
+			l.readChar()
+		} else {
+			l.errorAt(diag.ErrUnterminatedComment, l.spanFrom(pos), "unterminated block comment")
+		}
+	}
+
+	return token.Comment{Text: l.input[start:l.position], Pos: pos, End: l.pos()}
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+			tok = l.readTwoCharToken(token.EQ)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -68,35 +204,81 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.withAssign(newToken(token.PLUS, l.ch), token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.withAssign(newToken(token.MINUS, l.ch), token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
+			tok = l.readTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.withAssign(l.readTwoCharToken(token.SHIFT_RIGHT), token.SHIFT_RIGHT_ASSIGN)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.withAssign(l.readTwoCharToken(token.SHIFT_LEFT), token.SHIFT_LEFT_ASSIGN)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = l.withAssign(newToken(token.AMPERSAND, l.ch), token.AMPERSAND_ASSIGN)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = l.withAssign(newToken(token.PIPE, l.ch), token.PIPE_ASSIGN)
+		}
+	case '^':
+		tok = l.withAssign(newToken(token.CARET, l.ch), token.CARET_ASSIGN)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
+	case '%':
+		tok = l.withAssign(newToken(token.PERCENT, l.ch), token.PERCENT_ASSIGN)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.withAssign(newToken(token.SLASH, l.ch), token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.withAssign(l.readTwoCharToken(token.POWER), token.POWER_ASSIGN)
+		} else {
+			tok = l.withAssign(newToken(token.ASTERISK, l.ch), token.ASTERISK_ASSIGN)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+		return tok
+	case '`':
+		tok.Type = token.STRING
+		tok.Literal = l.readRawString()
+		return tok
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
-
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 
 	case 0:
 		tok.Literal = ""
@@ -108,8 +290,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -121,39 +302,263 @@ func (l *Lexer) NextToken() token.Token {
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a floating-point literal. Integers may use
+// a 0x, 0o or 0b prefix; floats such as 3.14, 1e9 or 2.5E-3 are decimal only.
+// A '.' only belongs to the number when a digit follows it, and '_' may be
+// used between digits as a separator.
+func (l *Lexer) readNumber() (string, token.Tokentype) {
+	pos := l.pos()
 	position := l.position
-	for isDigit(l.ch) {
+
+	if l.ch == '0' {
+		if base, name := prefixBase(l.peekChar()); base != 0 {
+			l.readChar()
+			l.readChar()
+			for isHexDigit(l.ch) || l.ch == '_' {
+				l.readChar()
+			}
+			lit := l.input[position:l.position]
+			l.checkDigits(pos, lit, base, name)
+			return lit, token.INT
+		}
+	}
+
+	tokType := token.Tokentype(token.INT)
+	l.readDecimals()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokType = token.FLOAT
 		l.readChar()
+		l.readDecimals()
 	}
-	return l.input[position:l.position]
+
+	if l.ch == 'e' || l.ch == 'E' {
+		tokType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.ch) {
+			l.errorAt(diag.ErrMalformedNumber, l.spanFrom(pos), "exponent has no digits")
+		}
+		l.readDecimals()
+	}
+
+	lit := l.input[position:l.position]
+	l.checkDigits(pos, lit, 10, "decimal")
+	return lit, tokType
 }
 
+func (l *Lexer) readDecimals() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// prefixBase returns the base selected by the character after a leading 0.
+func prefixBase(ch rune) (int, string) {
+	switch ch {
+	case 'x', 'X':
+		return 16, "hexadecimal"
+	case 'o', 'O':
+		return 8, "octal"
+	case 'b', 'B':
+		return 2, "binary"
+	}
+	return 0, ""
+}
+
+// checkDigits reports digits that are invalid in base and separators that do
+// not sit between two digits (a base prefix counts as a digit on its left).
+func (l *Lexer) checkDigits(pos token.Position, lit string, base int, name string) {
+	digits := lit
+	prev := ' '
+	if base != 10 {
+		digits = lit[2:]
+		prev = '0'
+		if strings.Trim(digits, "_") == "" {
+			l.errorAt(diag.ErrMalformedNumber, l.spanFrom(pos), "%s literal has no digits", name)
+			return
+		}
+	}
+
+	for _, ch := range digits {
+		switch {
+		case ch == '_':
+			if prev == '_' || prev == ' ' {
+				l.errorAt(diag.ErrMalformedNumber, l.spanFrom(pos), "'_' must separate successive digits")
+				return
+			}
+		case isHexDigit(ch) && base != 10 || isDigit(ch):
+			if digitValue(ch) >= base {
+				l.errorAt(diag.ErrMalformedNumber, l.spanFrom(pos), "invalid digit %q in %s literal", ch, name)
+				return
+			}
+		default:
+			// '.', exponent marker or sign in a decimal float
+			if prev == '_' {
+				l.errorAt(diag.ErrMalformedNumber, l.spanFrom(pos), "'_' must separate successive digits")
+				return
+			}
+			ch = ' '
+		}
+		prev = ch
+	}
+
+	if prev == '_' {
+		l.errorAt(diag.ErrMalformedNumber, l.spanFrom(pos), "'_' must separate successive digits")
+	}
+}
+
+func digitValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case ch >= 'a' && ch <= 'f':
+		return int(ch-'a') + 10
+	default:
+		return int(ch-'A') + 10
+	}
+}
+
+// readString reads a double-quoted string, decoding escape sequences, and
+// leaves the lexer after the closing quote. A string may not span lines.
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	pos := l.pos()
+	var out strings.Builder
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '"':
+			l.readChar()
+			return out.String()
+		case 0, '\n':
+			d := l.errorAt(diag.ErrUnterminatedString, l.spanFrom(pos), "unterminated string literal")
+			if l.ch == '\n' {
+				d.Hint = "use a backtick raw string for text that spans lines"
+			}
+			return out.String()
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape decodes the escape sequence starting at the current backslash.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.pos()
+	if next := l.peekChar(); next == 0 || next == '\n' {
+		// let readString report the unterminated literal
+		return
+	}
+
+	l.readChar()
+	switch l.ch {
+	case 'n':
+		out.WriteRune('\n')
+	case 't':
+		out.WriteRune('\t')
+	case 'r':
+		out.WriteRune('\r')
+	case '\\':
+		out.WriteRune('\\')
+	case '"':
+		out.WriteRune('"')
+	case 'u':
+		if l.peekChar() != '{' {
+			l.errorAt(diag.ErrInvalidEscape, l.spanThrough(pos), "invalid Unicode escape: expected \\u{...}")
+			return
+		}
+		l.readChar()
+
+		start := l.readPosition
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+		digits := l.input[start:l.readPosition]
+		if l.peekChar() != '}' {
+			l.errorAt(diag.ErrInvalidEscape, l.spanThrough(pos), "invalid Unicode escape: expected \\u{...}")
+			return
+		}
+		l.readChar()
+
+		code, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+			l.errorAt(diag.ErrInvalidEscape, l.spanThrough(pos), "invalid Unicode code point \\u{%s}", digits)
+			return
+		}
+		out.WriteRune(rune(code))
+	default:
+		d := l.errorAt(diag.ErrInvalidEscape, l.spanThrough(pos), "unknown escape sequence \\%c", l.ch)
+		d.Hint = `supported escapes are \n \t \r \\ \" and \u{...}`
+		out.WriteRune(l.ch)
+	}
+}
+
+// readRawString reads a backtick-quoted string. Raw strings may span lines
+// and take their content literally, except that carriage returns are dropped.
+func (l *Lexer) readRawString() string {
+	pos := l.pos()
+	var out strings.Builder
+
+	for {
+		l.readChar()
+		switch l.ch {
+		case '`':
+			l.readChar()
+			return out.String()
+		case 0:
+			l.errorAt(diag.ErrUnterminatedRaw, l.spanFrom(pos), "unterminated raw string literal")
+			return out.String()
+		case '\r':
+			// dropped so that files with CRLF line endings read the same
+		default:
+			out.WriteRune(l.ch)
 		}
 	}
-	return l.input[position:l.position]
 }
 
-func newToken(tokenType token.Tokentype, ch byte) token.Token {
+// readTwoCharToken consumes the next character and returns a token whose
+// literal is the current character followed by that one.
+func (l *Lexer) readTwoCharToken(tokenType token.Tokentype) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+// withAssign turns operator tok into its compound-assignment form (e.g. "+"
+// into "+=") when the next character is '='.
+func (l *Lexer) withAssign(tok token.Token, assignType token.Tokentype) token.Token {
+	if l.peekChar() != '=' {
+		return tok
+	}
+	l.readChar()
+	return token.Token{Type: assignType, Literal: tok.Literal + "="}
+}
+
+func newToken(tokenType token.Tokentype, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+// isLetter reports whether ch may start an identifier: any Unicode letter or
+// an underscore. Identifiers may also contain digits after the first rune.
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
@@ -14,7 +14,7 @@ func TestNextToken(t *testing.T) {
 		x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -139,3 +139,398 @@ func TestNextToken(t *testing.T) {
 	}
 
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + \"ab\""
+
+	tests := []struct {
+		expectedType  token.Tokentype
+		expectedStart string
+		expectedEnd   string
+	}{
+		{token.LET, "test.gc:1:1", "test.gc:1:4"},
+		{token.IDENT, "test.gc:1:5", "test.gc:1:6"},
+		{token.ASSIGN, "test.gc:1:7", "test.gc:1:8"},
+		{token.INT, "test.gc:1:9", "test.gc:1:10"},
+		{token.SEMICOLON, "test.gc:1:10", "test.gc:1:11"},
+		{token.IDENT, "test.gc:2:3", "test.gc:2:4"},
+		{token.PLUS, "test.gc:2:5", "test.gc:2:6"},
+		{token.STRING, "test.gc:2:7", "test.gc:2:11"},
+		{token.EOF, "test.gc:2:11", "test.gc:2:11"},
+	}
+
+	l := NewFile("test.gc", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.String() != tt.expectedStart {
+			t.Errorf("test[%d] - start wrong. expected=%q, got=%q", i, tt.expectedStart, tok.Pos)
+		}
+
+		if tok.End.String() != tt.expectedEnd {
+			t.Errorf("test[%d] - end wrong. expected=%q, got=%q", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ x /* inline */ + 1
+// dangling`
+
+	l := New(input)
+
+	tests := []struct {
+		expectedType     token.Tokentype
+		expectedLeading  []string
+		expectedTrailing []string
+	}{
+		{token.LET, []string{"// leading comment"}, nil},
+		{token.IDENT, nil, nil},
+		{token.ASSIGN, nil, nil},
+		{token.INT, nil, nil},
+		{token.SEMICOLON, nil, []string{"// trailing comment"}},
+		{token.IDENT, []string{"/* block\n   comment */"}, []string{"/* inline */"}},
+		{token.PLUS, nil, nil},
+		{token.INT, nil, nil},
+		{token.EOF, []string{"// dangling"}, nil},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		testComments(t, i, "leading", tok.Leading, tt.expectedLeading)
+		testComments(t, i, "trailing", tok.Trailing, tt.expectedTrailing)
+	}
+}
+
+func testComments(t *testing.T, i int, kind string, comments []token.Comment, expected []string) {
+	if len(comments) != len(expected) {
+		t.Errorf("test[%d] - wrong number of %s comments. expected=%d, got=%d", i, kind, len(expected), len(comments))
+		return
+	}
+
+	for j, c := range comments {
+		if c.Text != expected[j] {
+			t.Errorf("test[%d] - %s comment wrong. expected=%q, got=%q", i, kind, expected[j], c.Text)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := "let 名字 = \"你好，世界\";\nlet ünïcode_2 = 名字; é€"
+
+	tests := []struct {
+		expectedType    token.Tokentype
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "名字", "1:5"},
+		{token.ASSIGN, "=", "1:8"},
+		{token.STRING, "你好，世界", "1:10"},
+		{token.SEMICOLON, ";", "1:17"},
+		{token.LET, "let", "2:1"},
+		{token.IDENT, "ünïcode_2", "2:5"},
+		{token.ASSIGN, "=", "2:15"},
+		{token.IDENT, "名字", "2:17"},
+		{token.SEMICOLON, ";", "2:19"},
+		{token.IDENT, "é", "2:21"},
+		{token.ILLEGAL, "€", "2:22"},
+		{token.EOF, "", "2:23"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("test[%d] - position wrong. expected=%q, got=%q", i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\"b"`, `a"b`},
+		{`"line\nbreak\ttab\\"`, "line\nbreak\ttab\\"},
+		{`"\u{4e2d}\u{6587}"`, "中文"},
+		{`"\u{1F600}"`, "\U0001F600"},
+		{"`raw \\n\nstring`", "raw \\n\nstring"},
+		{"`a\r\nb`", "a\nb"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tokentype wrong. expected=%q, got=%q", token.STRING, tok.Type)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Errorf("literal wrong. expected=%q, got=%q", tt.expected, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("unexpected lexer errors for %s: %v", tt.input, l.Errors())
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("expected EOF after string. got=%q", next.Type)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "abc`, "1:9: unterminated string literal"},
+		{"\"abc\nlet x", "1:1: unterminated string literal"},
+		{"x; `abc", "1:4: unterminated raw string literal"},
+		{`"a\qb"`, "1:3: unknown escape sequence \\q"},
+		{`"\u{110000}"`, "1:2: invalid Unicode code point \\u{110000}"},
+		{`"\u41"`, "1:2: invalid Unicode escape: expected \\u{...}"},
+		{"1 /* never closed", "1:3: unterminated block comment"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `5 3.14 1e9 2.5E-3 6e+2 7.foo [1.5]`
+
+	tests := []struct {
+		expectedType    token.Tokentype
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "foo"},
+		{token.LBRACKET, "["},
+		{token.FLOAT, "1.5"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+
+	l = New("1e+")
+	l.NextToken()
+	if len(l.Errors()) != 1 || l.Errors()[0] != "1:1: exponent has no digits" {
+		t.Errorf("expected exponent error. got=%v", l.Errors())
+	}
+}
+
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	input := `a <= b >= c && d || e < f`
+
+	tests := []struct {
+		expectedType    token.Tokentype
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.LT, "<"},
+		{token.IDENT, "f"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestArithmeticAndBitwiseOperators(t *testing.T) {
+	input := `a % b ** c & d | e ^ ~f << g >> h`
+
+	tests := []struct {
+		expectedType    token.Tokentype
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "b"},
+		{token.POWER, "**"},
+		{token.IDENT, "c"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "d"},
+		{token.PIPE, "|"},
+		{token.IDENT, "e"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENT, "f"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.IDENT, "g"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENT, "h"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestCompoundAssignmentOperators(t *testing.T) {
+	input := `+= -= *= /= %= **= &= |= ^= <<= >>= = ==`
+
+	expected := []token.Tokentype{
+		token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN,
+		token.PERCENT_ASSIGN, token.POWER_ASSIGN, token.AMPERSAND_ASSIGN, token.PIPE_ASSIGN,
+		token.CARET_ASSIGN, token.SHIFT_LEFT_ASSIGN, token.SHIFT_RIGHT_ASSIGN, token.ASSIGN,
+		token.EQ, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+
+		if tok.Type != tt {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+
+		if tt != token.EOF && tok.Literal != string(tt) {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt, tok.Literal)
+		}
+	}
+}
+
+func TestShebangLine(t *testing.T) {
+	l := NewFile("script.gc", "#!/usr/bin/env goclang run\nlet x = 1;")
+
+	tok := l.NextToken()
+	if tok.Type != token.LET {
+		t.Fatalf("shebang line not skipped. got=%q (%q)", tok.Type, tok.Literal)
+	}
+	if tok.Pos.String() != "script.gc:2:1" {
+		t.Errorf("wrong position after shebang. got=%q", tok.Pos)
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := `fn(a, ...rest) { f(...rest) } .. .`
+	tests := []struct {
+		expectedType    token.Tokentype
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
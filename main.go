@@ -1,19 +1,170 @@
 package main
 
 import (
+	"GoClang/diag"
+	"GoClang/evaluator"
+	"GoClang/lexer"
+	"GoClang/object"
+	"GoClang/parser"
 	"GoClang/repl"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 )
 
+const usage = `usage:
+  goclang                        start the interactive REPL
+  goclang run FILE [ARGS...]     run the script in FILE
+  goclang -e PROGRAM [ARGS...]   run PROGRAM and print its value
+  goclang < FILE                 run a program read from standard input
+
+flags:
+  -error-format human|json       how errors are written to standard error
+
+The script arguments are available to the program as the array args.
+Exit status is 0 on success, 1 if the program has errors and 2 for usage
+errors.
+`
+
 func main() {
-	usr, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the goclang command line and returns the exit status.
+func run(argv []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("goclang", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	expr := fs.String("e", "", "run `program` and print its value")
+	errorFormat := fs.String("error-format", "human", "error output `format`: human or json")
+
+	if err := fs.Parse(argv); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *errorFormat != "human" && *errorFormat != "json" {
+		fmt.Fprintf(stderr, "goclang: unknown error format %q\n", *errorFormat)
+		return 2
+	}
+
+	exprSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "e" {
+			exprSet = true
+		}
+	})
+
+	r := &runner{stdout: stdout, stderr: stderr, json: *errorFormat == "json"}
+	args := fs.Args()
+
+	switch {
+	case exprSet:
+		return r.execute("<expr>", *expr, args, true)
+
+	case len(args) > 0 && args[0] == "run":
+		if len(args) < 2 {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		src, err := os.ReadFile(args[1])
+		if err != nil {
+			fmt.Fprintf(stderr, "goclang: %v\n", err)
+			return 1
+		}
+		return r.execute(args[1], string(src), args[2:], false)
+
+	case len(args) > 0:
+		fmt.Fprintf(stderr, "goclang: unknown command %q\n", args[0])
+		fmt.Fprint(stderr, usage)
+		return 2
+
+	case isTerminal(stdin):
+		greet(stdout)
+		repl.Start(stdin, stdout)
+		return 0
+
+	default:
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "goclang: %v\n", err)
+			return 1
+		}
+		return r.execute("<stdin>", string(src), nil, false)
+	}
+}
+
+type runner struct {
+	stdout io.Writer
+	stderr io.Writer
+	json   bool // write errors as JSON instead of for people
+}
+
+// execute parses and evaluates src with args bound to the array args. When
+// printResult is set, the value of the program is printed unless it is null.
+func (r *runner) execute(filename, src string, args []string, printResult bool) int {
+	l := lexer.NewFile(filename, src)
+	p := parser.New(l)
+	program := p.ParserProgram()
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		r.report(src, diags)
+		return 1
+	}
+
+	env := object.NewEnviroment()
+	env.Set("args", stringArray(args))
+
+	result := evaluator.Eval(program, env)
+	if err, ok := result.(*object.Error); ok {
+		r.report(src, []diag.Diagnostic{err.Diagnostic()})
+		return 1
+	}
+
+	if printResult && result != nil && result.Type() != object.NULL_OBJ {
+		fmt.Fprintln(r.stdout, result.Inspect())
+	}
+	return 0
+}
+
+func (r *runner) report(src string, diags []diag.Diagnostic) {
+	if r.json {
+		diag.WriteJSON(r.stderr, diags)
+		return
+	}
+	diag.RenderAll(r.stderr, src, diags)
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// isTerminal reports whether in is a character device such as a terminal,
+// as opposed to a pipe or a file.
+func isTerminal(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
 	if err != nil {
-		panic(err)
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func greet(out io.Writer) {
+	name := "there"
+	if usr, err := user.Current(); err == nil {
+		name = usr.Username
 	}
 
-	fmt.Printf("Hello, %s! This is The GoClang Programming Language.\n", usr.Username)
-	fmt.Printf("Feel free to type in command!\n")
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Fprintf(out, "Hello, %s! This is The GoClang Programming Language.\n", name)
+	fmt.Fprintf(out, "Feel free to type in command!\n")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "sum.gc")
+	src := "#!/usr/bin/env goclang run\nlet total = 0;\nfor (a in args) { total += len(a) }\nif (total != 5) { total + true }\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedStatus int
+		expectedOut    string
+		expectedErr    string
+	}{
+		{[]string{"-e", "1 + 2"}, "", 0, "3\n", ""},
+		{[]string{"-e", "let x = 1;"}, "", 0, "", ""},
+		{[]string{"-e", "len(args)", "a", "b"}, "", 0, "2\n", ""},
+		{[]string{"-e", "1 + true"}, "", 1, "", "error[E0102]: type mismatch: INTEGER + BOOLEAN"},
+		{[]string{"-e", "let x 1"}, "", 1, "", " --> <expr>:1:7"},
+		{[]string{"-error-format", "json", "-e", "foo"}, "", 1, "", `"code":"E0100"`},
+		{[]string{"-e", "let f = fn(x) { x + true };\nf(1)"}, "", 1, "", "  = in f(1), called at <expr>:2:1"},
+		{[]string{"-e", "let f = fn(n) { 1 + f(n + 1) }; f(0)"}, "", 1, "", "error[E0115]: maximum recursion depth exceeded"},
+		{[]string{"run", script, "ab", "cde"}, "", 0, "", ""},
+		{[]string{"run", filepath.Join(dir, "missing.gc")}, "", 1, "", "goclang: open"},
+		{[]string{"run"}, "", 2, "", "usage:"},
+		{[]string{"frobnicate"}, "", 2, "", `unknown command "frobnicate"`},
+		{[]string{"-error-format", "xml", "-e", "1"}, "", 2, "", `unknown error format "xml"`},
+		{nil, "let a = 2;\na * 21", 0, "", ""},
+		{nil, "a * 21", 1, "", " --> <stdin>:1:1"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: wrong exit status. expected=%d, got=%d (stderr=%q)",
+				tt.args, tt.expectedStatus, status, stderr.String())
+		}
+		if tt.expectedOut != "" && stdout.String() != tt.expectedOut {
+			t.Errorf("%v: wrong output. expected=%q, got=%q", tt.args, tt.expectedOut, stdout.String())
+		}
+		if tt.expectedErr == "" && stderr.Len() != 0 {
+			t.Errorf("%v: unexpected error output %q", tt.args, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedErr) {
+			t.Errorf("%v: error output %q does not contain %q", tt.args, stderr.String(), tt.expectedErr)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
@@ -28,3 +30,34 @@ func (e *Environment) Set(name string, value Object) Object {
 	e.store[name] = value
 	return value
 }
+
+// Assign rebinds name in the nearest enclosing environment that already
+// defines it. It reports false if name is not bound anywhere.
+func (e *Environment) Assign(name string, value Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = value
+		return value, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, value)
+	}
+	return nil, false
+}
+
+// Names returns the names visible in e, including those inherited from outer
+// environments, in sorted order.
+func (e *Environment) Names() []string {
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
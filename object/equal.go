@@ -0,0 +1,89 @@
+package object
+
+// Equal reports whether a and b are equal values, as compared by == and !=:
+//
+//   - integers and floats are equal if they have the same numeric value, so
+//     1 == 1.0;
+//   - strings and booleans are equal if they have the same value, and null
+//     is equal to null;
+//   - arrays are equal if they have the same length and equal elements in
+//     the same order;
+//   - hashes are equal if they have the same keys mapped to equal values, in
+//     any order;
+//   - functions, builtins and all other values are only equal to themselves.
+//
+// Values of different types are otherwise never equal. Arrays and hashes
+// that contain themselves are compared without looping forever.
+func Equal(a, b Object) bool {
+	return equal(a, b, make(map[[2]Object]bool))
+}
+
+// equal compares a and b. comparing holds the pairs of arrays and hashes
+// being compared further up; meeting one again means a cycle, which is
+// assumed equal so that the rest of the structure decides.
+func equal(a, b Object, comparing map[[2]Object]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || comparing[[2]Object{a, b}] {
+			return true
+		}
+		comparing[[2]Object{a, b}] = true
+		defer delete(comparing, [2]Object{a, b})
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || comparing[[2]Object{a, b}] {
+			return true
+		}
+		comparing[[2]Object{a, b}] = true
+		defer delete(comparing, [2]Object{a, b})
+
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !equal(pair.Value, other.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a == b
+}
@@ -2,25 +2,31 @@ package object
 
 import (
 	"GoClang/ast"
+	"GoClang/diag"
+	"GoClang/token"
 	"bytes"
 	"fmt"
-	"strings"
 	"hash/fnv"
+	"strconv"
+	"strings"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	STRING_OBJ       = "STRING"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
-	HASH_OBJ		 = "HASH"
+	HASH_OBJ         = "HASH"
 )
 
 type Object interface {
@@ -44,6 +50,24 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+type Float struct {
+	Value float64
+}
+
+// Inspect prints the shortest representation that reads back as the same
+// value, keeping a ".0" on whole numbers so they are not mistaken for integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -78,8 +102,66 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break and Continue signal loop control flow out of a loop body, the same
+// way ReturnValue signals a return out of a function body.
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
 type Error struct {
+	Code    string // diagnostic code, see package diag
 	Message string
+	Pos     token.Position // start of the node that raised the error, if known
+	End     token.Position // end of that node
+	Hint    string
+	Stack   []Frame // calls active when the error was raised, outermost first
+}
+
+// Frame is a call of a function.
+type Frame struct {
+	Function *Function
+	Args     []Object
+	Call     *ast.CallExpression
+}
+
+// maxArgWidth is the length after which arguments are shortened in stack
+// traces.
+const maxArgWidth = 32
+
+// Diagnostic returns the frame as shown in stack traces, e.g. `add(1, "x")`.
+func (f Frame) Diagnostic() diag.Frame {
+	args := make([]string, len(f.Args))
+	for i, a := range f.Args {
+		s := a.Inspect()
+		if a.Type() == STRING_OBJ {
+			s = strconv.Quote(s)
+		}
+		if r := []rune(s); len(r) > maxArgWidth {
+			s = string(r[:maxArgWidth-3]) + "..."
+		}
+		args[i] = s
+	}
+	return diag.Frame{
+		Function: f.Function.DisplayName(),
+		Args:     args,
+		Span:     diag.Span{Start: f.Call.Pos(), End: f.Call.End()},
+	}
 }
 
 func (e *Error) Type() ObjectType {
@@ -87,13 +169,43 @@ func (e *Error) Type() ObjectType {
 }
 
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
 	return "ERROR: " + e.Message
 }
 
+// Diagnostic returns the error as a diagnostic spanning the node that raised
+// it.
+func (e *Error) Diagnostic() diag.Diagnostic {
+	d := diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     e.Code,
+		Message:  e.Message,
+		Span:     diag.Span{Start: e.Pos, End: e.End},
+		Hint:     e.Hint,
+	}
+	for _, f := range e.Stack {
+		d.Stack = append(d.Stack, f.Diagnostic())
+	}
+	return d
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // evaluated at call time, nil for required parameters
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string // the name given by `let`, "" for anonymous functions
+}
+
+// DisplayName returns the name of f, or "fn" if it has none.
+func (f *Function) DisplayName() string {
+	if f.Name == "" {
+		return "fn"
+	}
+	return f.Name
 }
 
 func (f *Function) Type() ObjectType {
@@ -104,8 +216,15 @@ func (f *Function) Inspect() string {
 
 	params := []string{}
 
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -150,70 +269,172 @@ func (a *Array) Type() ObjectType {
 	return ARRAY_OBJ
 }
 func (a *Array) Inspect() string {
-	var out bytes.Buffer
+	return inspect(a, make(map[Object]bool))
+}
 
-	elements := []string{}
+// inspect returns the printed form of obj. printing holds the arrays and
+// hashes being printed further up; meeting one of them again means a value
+// contains itself, which is printed as [...] or {...}.
+func inspect(obj Object, printing map[Object]bool) string {
+	var out bytes.Buffer
 
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if printing[obj] {
+			return "[...]"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, printing))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ","))
+		out.WriteString("]")
+
+	case *Hash:
+		if printing[obj] {
+			return "{...}"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", inspect(pair.Key, printing), inspect(pair.Value, printing)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+
+	default:
+		return obj.Inspect()
 	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ","))
-	out.WriteString("]")
 	return out.String()
 }
 
+// HashKey is the hash of a key. Different keys may have the same HashKey;
+// Hash compares the keys themselves when they do.
 type HashKey struct {
-	Type ObjectType
+	Type  ObjectType
 	Value uint64
 }
 
-func (b *Boolean) HashKey() HashKey{
+func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
 	if b.Value {
 		value = 1
-	}else {
+	} else {
 		value = 0
 	}
 
-	return HashKey{Type:b.Type(), Value:value}
+	return HashKey{Type: b.Type(), Value: value}
 }
 
 func (i *Integer) HashKey() HashKey {
-	return HashKey{Type:i.Type(), Value:uint64(i.Value)}
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
-func (s *String) HashKey() HashKey {
+// hashString hashes string keys. It is a variable so that tests can force
+// collisions.
+var hashString = func(s string) uint64 {
 	h := fnv.New64a()
-	h.Write([]byte(s.Value))
-	return HashKey{Type:s.Type(), Value:h.Sum64()}
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Value: hashString(s.Value)}
+}
+
+// keysEqual reports whether a and b are the same hash key.
+func keysEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	}
+	return a == b
 }
 
 type HashPair struct {
-	Key Object
+	Key   Object
 	Value Object
 }
 
+// Hash maps hashable keys to values and remembers the order in which keys
+// were first inserted; Pairs, Inspect and iteration follow that order.
 type Hash struct {
-	Pair map[HashKey]HashPair
+	// index maps each HashKey to the positions in pairs of the keys that
+	// hash to it; there is more than one only on a collision.
+	index map[HashKey][]int
+	pairs []HashPair
 }
 
-func (h *Hash) Type()  ObjectType{
-	return HASH_OBJ
+func NewHash() *Hash {
+	return &Hash{index: make(map[HashKey][]int)}
 }
 
-func (h *Hash)Inspect()  string{
-	var out bytes.Buffer
+// find returns the position of key in h.pairs, or -1.
+func (h *Hash) find(hashed HashKey, key Object) int {
+	for _, i := range h.index[hashed] {
+		if keysEqual(h.pairs[i].Key, key) {
+			return i
+		}
+	}
+	return -1
+}
 
-	pairs := []string{}
-	for _, pair := range h.Pair{
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+// Get returns the pair stored under key.
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	i := h.find(key.HashKey(), key.(Object))
+	if i < 0 {
+		return HashPair{}, false
 	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs,", "))
-	out.WriteString("}")
+	return h.pairs[i], true
+}
 
-	return out.String()
+// Set stores value under key. A key that is already present keeps its
+// position.
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.index == nil {
+		h.index = make(map[HashKey][]int)
+	}
+	hashed := key.HashKey()
+	pair := HashPair{Key: key.(Object), Value: value}
+	if i := h.find(hashed, pair.Key); i >= 0 {
+		h.pairs[i] = pair
+		return
+	}
+	h.index[hashed] = append(h.index[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, pair)
+}
+
+// Pairs returns the entries in insertion order. The slice must not be
+// modified.
+func (h *Hash) Pairs() []HashPair {
+	return h.pairs
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	return inspect(h, make(map[Object]bool))
 }
@@ -20,3 +20,70 @@ func TestStringHashKey(t *testing.T)  {
 		t.Errorf("string with different content have same hash keys")
 	}
 }
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{1e21, "1e+21"},
+		{0.30000000000000004, "0.30000000000000004"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Float.Inspect() wrong. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	defer func(saved func(string) uint64) { hashString = saved }(hashString)
+	hashString = func(string) uint64 { return 42 }
+
+	a := &String{Value: "a"}
+	b := &String{Value: "b"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatal("hasher not injected")
+	}
+
+	h := NewHash()
+	h.Set(a, &Integer{Value: 1})
+	h.Set(b, &Integer{Value: 2})
+	h.Set(&String{Value: "a"}, &Integer{Value: 3})
+
+	if h.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got %d pairs", h.Len())
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected int64
+		found    bool
+	}{
+		{&String{Value: "a"}, 3, true},
+		{&String{Value: "b"}, 2, true},
+		{&String{Value: "c"}, 0, false},
+		// a key of another type with the same hash value is a different key
+		{&Integer{Value: 42}, 0, false},
+	}
+
+	for _, tt := range tests {
+		pair, ok := h.Get(tt.key)
+		if ok != tt.found {
+			t.Errorf("Get(%s) found=%t, want %t", tt.key.(Object).Inspect(), ok, tt.found)
+			continue
+		}
+		if ok && pair.Value.(*Integer).Value != tt.expected {
+			t.Errorf("Get(%s) = %s, want %d", tt.key.(Object).Inspect(), pair.Value.Inspect(), tt.expected)
+		}
+	}
+
+	if h.Inspect() != "{a: 3, b: 2}" {
+		t.Errorf("wrong Inspect. got=%s", h.Inspect())
+	}
+}
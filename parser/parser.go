@@ -2,18 +2,31 @@ package parser
 
 import (
 	"GoClang/ast"
+	"GoClang/diag"
 	"GoClang/lexer"
 	"GoClang/token"
-	"fmt"
+	"errors"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
 	l *lexer.Lexer
 
-	errors    []string
-	curToken  token.Token
-	peekToken token.Token
+	diagnostics []diag.Diagnostic
+	lexerErrors int // number of lexer diagnostics already copied into diagnostics
+	loopDepth   int // number of enclosing loops in the current function
+	curToken    token.Token
+	peekToken   token.Token
+
+	// Error recovery: after a syntax error the parser is panicking until
+	// the statement containing the error has been skipped. reported holds
+	// the positions that already have an error, so that follow-on errors
+	// for the same token are dropped.
+	panicking  bool
+	reported   map[token.Position]bool
+	braceDepth int  // number of unclosed '{' before curToken
+	atBlockEnd bool // recovery stopped on the '}' closing the current block
 
 	prefixParseFns map[token.Tokentype]prefixParseFn
 	infixParseFns  map[token.Tokentype]infixParseFn
@@ -21,15 +34,17 @@ type Parser struct {
 
 func New(l *lexer.Lexer) *Parser {
 	p := Parser{l: l,
-		errors: []string{}}
+		reported: make(map[token.Position]bool)}
 	p.nextToken()
 	p.nextToken()
 
 	p.prefixParseFns = make(map[token.Tokentype]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parserIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBooleanExpression)
 	p.registerPrefix(token.FALSE, p.parseBooleanExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
@@ -44,10 +59,33 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.POWER_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.AMPERSAND_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PIPE_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.CARET_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SHIFT_LEFT_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SHIFT_RIGHT_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	return &p
@@ -61,18 +99,68 @@ func (p *Parser) registerInfix(tokenType token.Tokentype, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// Errors returns the lexer and parser errors, each prefixed with its source
+// position.
 func (p *Parser) Errors() []string {
-	return p.errors
+	return diag.Strings(p.diagnostics)
+}
+
+// Diagnostics returns the lexer and parser errors in source order.
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	return p.diagnostics
+}
+
+// errorAt records a parse error and returns it so that the caller can add a
+// hint or related spans. Errors reported while panicking, or at a position
+// that already has an error, are dropped as follow-on errors and errorAt
+// returns nil.
+func (p *Parser) errorAt(code string, span diag.Span, format string, a ...interface{}) *diag.Diagnostic {
+	if p.panicking || p.reported[span.Start] {
+		return nil
+	}
+	p.reported[span.Start] = true
+
+	p.diagnostics = append(p.diagnostics, diag.Errorf(code, span, format, a...))
+	return &p.diagnostics[len(p.diagnostics)-1]
+}
+
+// syntaxErrorAt records an error after which the parser can no longer trust
+// its position in the token stream, and starts skipping the statement.
+func (p *Parser) syntaxErrorAt(code string, span diag.Span, format string, a ...interface{}) *diag.Diagnostic {
+	d := p.errorAt(code, span, format, a...)
+	p.panicking = true
+	return d
 }
 
 func (p *Parser) peekError(t token.Tokentype) {
-	msg := fmt.Sprintf("expected next token to be %s; got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.syntaxErrorAt(diag.ErrUnexpectedToken, tokenSpan(p.peekToken),
+		"expected next token to be %s; got %s instead", t, p.peekToken.Type)
+}
+
+func tokenSpan(tok token.Token) diag.Span {
+	return diag.Span{Start: tok.Pos, End: tok.End}
+}
+
+func nodeSpan(node ast.Node) diag.Span {
+	return diag.Span{Start: node.Pos(), End: node.End()}
 }
 
 func (p *Parser) nextToken() {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		p.braceDepth--
+	}
+
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	if lexErrs := p.l.Diagnostics(); len(lexErrs) > p.lexerErrors {
+		p.diagnostics = append(p.diagnostics, lexErrs[p.lexerErrors:]...)
+		p.lexerErrors = len(lexErrs)
+		p.reported[p.peekToken.Pos] = true
+	}
 }
 
 func (p *Parser) ParserProgram() *ast.Program {
@@ -80,21 +168,86 @@ func (p *Parser) ParserProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parserStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		// empty statement, e.g. after a loop: while (c) { ... };
+		if p.curTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			continue
 		}
+		stmt := p.parserStatement()
+		program.Statements = append(program.Statements, stmt)
+		// a stray '}' at the top level has nothing to close
+		p.atBlockEnd = false
 		p.nextToken()
 	}
 	return program
 }
 
+// parserStatement parses one statement. After a syntax error it skips the
+// rest of the statement and returns an *ast.BadStatement in its place.
 func (p *Parser) parserStatement() ast.Statement {
+	start := p.curToken.Pos
+	depth := p.braceDepth
+
+	stmt := p.parseStatementKind()
+	if p.panicking || stmt == nil {
+		p.atBlockEnd = p.synchronize(depth)
+		p.panicking = false
+		return &ast.BadStatement{From: start, To: p.curToken.End}
+	}
+	return stmt
+}
+
+// synchronize skips tokens until curToken is the last token of the bad
+// statement: a ';', or the token before a statement keyword or before the '}'
+// closing the enclosing block. Braces opened inside the statement are
+// skipped as a whole. It reports whether it stopped on a '}' that closes the
+// enclosing block.
+func (p *Parser) synchronize(depth int) bool {
+	for !p.curTokenIs(token.EOF) {
+		if p.braceDepth == depth && p.curTokenIs(token.RBRACE) {
+			return true
+		}
+
+		after := p.braceDepth
+		switch p.curToken.Type {
+		case token.LBRACE:
+			after++
+		case token.RBRACE:
+			after--
+		}
+		if after == depth && (p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) ||
+			p.peekTokenIs(token.EOF) || isStatementKeyword(p.peekToken.Type)) {
+			return false
+		}
+		p.nextToken()
+	}
+	return false
+}
+
+func isStatementKeyword(t token.Tokentype) bool {
+	switch t {
+	case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) badExpression(from token.Position) ast.Expression {
+	return &ast.BadExpression{From: from, To: p.curToken.End}
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parserLetStatement()
 	case token.RETURN:
 		return p.parserReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	default:
 		return p.parserExpressionStatement()
 	}
@@ -116,8 +269,11 @@ func (p *Parser) parserLetStatement() ast.Statement {
 	p.nextToken()
 
 	stmt.Value = p.parserExpression(LOWEST)
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -130,6 +286,88 @@ func (p *Parser) parserReturnStatement() ast.Statement {
 	p.nextToken()
 
 	stmt.ReturnValue = p.parserExpression(LOWEST)
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parserExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parserExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	return stmt
+}
+
+// parseLoopControlStatement parses `break` and `continue`, which are only
+// allowed inside a loop body of the current function.
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
+	if p.loopDepth == 0 {
+		d := p.errorAt(diag.ErrLoopControlOutside, tokenSpan(p.curToken), "%s is not in a loop", p.curToken.Literal)
+		if d != nil {
+			d.Hint = "break and continue can only be used inside a while or for loop"
+		}
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -140,7 +378,7 @@ func (p *Parser) parserExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parserExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -149,29 +387,40 @@ func (p *Parser) parserExpressionStatement() ast.Statement {
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = or += etc., right-associative
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      //==
-	LESSGREATER //< or >
+	LESSGREATER //< or > or <= or >=
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
 	SUM         //+
-	PRODUCT     //-
-	PREFIX      //-X or !X
+	PRODUCT     //* or / or %
+	PREFIX      //-X or !X or ~X
+	POWER       // ** (binds tighter than a prefix operator: -2 ** 2 == -4)
 	CALL        // myFunction(X)
 	INDEX
 )
 
 func (p *Parser) noPrefixParseFnError(t token.Tokentype) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		p.syntaxErrorAt(diag.ErrIllegalCharacter, tokenSpan(p.curToken), "illegal character %q", p.curToken.Literal)
+		return
+	}
+	p.syntaxErrorAt(diag.ErrMissingExpression, tokenSpan(p.curToken), "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parserExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return p.badExpression(p.curToken.Pos)
 	}
 	leftexp := prefix()
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	for !p.panicking && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftexp
@@ -212,11 +461,52 @@ func (p *Parser) parserIdentifier() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+	digits := strings.ReplaceAll(p.curToken.Literal, "_", "")
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			digits = digits[2:]
+		}
+	}
+
+	value, err := strconv.ParseInt(digits, base, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		if errors.Is(err, strconv.ErrRange) {
+			d := p.errorAt(diag.ErrNumberOutOfRange, tokenSpan(p.curToken),
+				"integer literal %s out of range (must fit in a signed 64-bit integer)", p.curToken.Literal)
+			if d != nil {
+				d.Hint = "use a float literal for larger magnitudes"
+			}
+		} else {
+			p.errorAt(diag.ErrMalformedNumber, tokenSpan(p.curToken), "could not parse %q as integer", p.curToken.Literal)
+		}
+		return p.badExpression(lit.Token.Pos)
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			p.errorAt(diag.ErrNumberOutOfRange, tokenSpan(p.curToken), "float literal %s out of range", p.curToken.Literal)
+		} else {
+			p.errorAt(diag.ErrMalformedNumber, tokenSpan(p.curToken), "could not parse %q as float", p.curToken.Literal)
+		}
+		return p.badExpression(lit.Token.Pos)
 	}
 
 	lit.Value = value
@@ -238,23 +528,73 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{Token: p.curToken, Left: left, Operator: p.curToken.Literal}
 
 	precedence := p.curPrecedence()
+	if expression.Operator == "**" {
+		// right-associative: 2 ** 3 ** 2 == 2 ** (3 ** 2)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parserExpression(precedence)
 
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+
+	p.nextToken()
+	expression.Value = p.parserExpression(ASSIGN - 1)
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	case *ast.BadExpression:
+		// already reported
+	default:
+		d := p.errorAt(diag.ErrInvalidAssignment, nodeSpan(target), "invalid assignment target: %s", target.String())
+		if d != nil {
+			d.Hint = "only variables and index expressions such as a[i] can be assigned to"
+		}
+		return &ast.BadExpression{From: target.Pos(), To: expression.End()}
+	}
+
+	return expression
+}
+
 var precedences = map[token.Tokentype]int{
+	token.ASSIGN:             ASSIGN,
+	token.PLUS_ASSIGN:        ASSIGN,
+	token.MINUS_ASSIGN:       ASSIGN,
+	token.ASTERISK_ASSIGN:    ASSIGN,
+	token.SLASH_ASSIGN:       ASSIGN,
+	token.PERCENT_ASSIGN:     ASSIGN,
+	token.POWER_ASSIGN:       ASSIGN,
+	token.AMPERSAND_ASSIGN:   ASSIGN,
+	token.PIPE_ASSIGN:        ASSIGN,
+	token.CARET_ASSIGN:       ASSIGN,
+	token.SHIFT_LEFT_ASSIGN:  ASSIGN,
+	token.SHIFT_RIGHT_ASSIGN: ASSIGN,
+
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
+	token.AND:      LOGICAL_AND,
+	token.OR:       LOGICAL_OR,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.ASTERISK: PRODUCT,
 	token.SLASH:    PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.PERCENT:  PRODUCT,
+	token.POWER:    POWER,
+
+	token.PIPE:        BIT_OR,
+	token.CARET:       BIT_XOR,
+	token.AMPERSAND:   BIT_AND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -276,12 +616,13 @@ func (p *Parser) parseBooleanExpression() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	start := p.curToken
 	p.nextToken()
 
 	exp := p.parserExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(start.Pos)
 	}
 
 	return exp
@@ -291,7 +632,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(expression.Token.Pos)
 	}
 
 	p.nextToken()
@@ -299,11 +640,11 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expression.Condition = p.parserExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(expression.Token.Pos)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(expression.Token.Pos)
 	}
 
 	expression.Consequence = p.parseBlockStatement()
@@ -312,7 +653,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		p.nextToken()
 
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return p.badExpression(expression.Token.Pos)
 		}
 
 		expression.Alternative = p.parseBlockStatement()
@@ -329,12 +670,27 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			continue
+		}
 		stmt := p.parserStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
+		block.Statements = append(block.Statements, stmt)
+		if p.atBlockEnd {
+			p.atBlockEnd = false
+			break
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
+
+	if !p.curTokenIs(token.RBRACE) {
+		d := p.syntaxErrorAt(diag.ErrUnexpectedToken, tokenSpan(p.curToken),
+			"expected next token to be }; got %s instead", p.curToken.Type)
+		if d != nil {
+			d.Related = append(d.Related, diag.Related{Span: tokenSpan(block.Token), Message: "unclosed block starts here"})
+		}
+	}
 
 	return block
 }
@@ -343,52 +699,88 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	funLit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(funLit.Token.Pos)
 	}
 
-	funLit.Parameters = p.parseParameters()
+	if !p.parseParameters(funLit) {
+		return p.badExpression(funLit.Token.Pos)
+	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(funLit.Token.Pos)
 	}
 
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	funLit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return funLit
 }
 
-func (p *Parser) parseParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseParameters parses `(a, b = default, ...rest)` into fn. Parameters
+// with defaults must follow those without, and the rest parameter must come
+// last.
+func (p *Parser) parseParameters(fn *ast.FunctionLiteral) bool {
+	fn.Parameters = []*ast.Identifier{}
+	fn.Defaults = []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			fn.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if p.peekTokenIs(token.COMMA) {
+				p.syntaxErrorAt(diag.ErrInvalidParameter, tokenSpan(p.peekToken),
+					"rest parameter ...%s must be the last parameter", fn.Rest.Value)
+				return false
+			}
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			value = p.parserExpression(LOWEST)
+		} else if n := len(fn.Defaults); n > 0 && fn.Defaults[n-1] != nil {
+			p.errorAt(diag.ErrInvalidParameter, nodeSpan(ident),
+				"parameter %s without a default follows a parameter with a default", ident.Value)
+		}
+		fn.Parameters = append(fn.Parameters, ident)
+		fn.Defaults = append(fn.Defaults, value)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
-	return identifiers
+
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	callExp := &ast.CallExpression{Token: p.curToken, Function: function}
-	callExp.Arguments = p.parseExpressionList(token.RPAREN)
+	callExp.Arguments = p.parseCallArguments()
+	callExp.Rparen = p.curToken
 
 	return callExp
 }
 
+// parseCallArguments parses an argument list, in which `...array` passes the
+// elements of array as separate arguments.
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
@@ -398,12 +790,12 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	}
 
 	p.nextToken()
-	args = append(args, p.parserExpression(LOWEST))
+	args = append(args, p.parseCallArgument())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parserExpression(LOWEST))
+		args = append(args, p.parseCallArgument())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -413,6 +805,17 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
+func (p *Parser) parseCallArgument() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parserExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parserExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) parseStringExpression() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -420,6 +823,7 @@ func (p *Parser) parseStringExpression() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 	return array
 }
 
@@ -454,34 +858,36 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp.Index = p.parserExpression(LOWEST)
 
 	if !p.expectPeek(token.RBRACKET) {
-		return nil
+		return p.badExpression(exp.Token.Pos)
 	}
+	exp.Rbracket = p.curToken
 	return exp
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
-	hash := &ast.HashLiteral{Token:p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []ast.HashPair{}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 		key := p.parserExpression(LOWEST)
 
 		if !p.expectPeek(token.COLON) {
-			return nil
+			return p.badExpression(hash.Token.Pos)
 		}
 
 		p.nextToken()
 		value := p.parserExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+			return p.badExpression(hash.Token.Pos)
 		}
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return p.badExpression(hash.Token.Pos)
 	}
+	hash.Rbrace = p.curToken
 	return hash
 }
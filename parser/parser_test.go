@@ -139,6 +139,103 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o755", 493},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_FF_FF", 65535},
+		{"0b1111_0000", 240},
+		{"010", 10},
+		{"0x7FFFFFFFFFFFFFFF", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParserProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Errorf("exp is not ast.IntegerLiteral. got=%T", stmt.Expression)
+			continue
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value for %q not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestIntegerLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 0x", "1:9: hexadecimal literal has no digits"},
+		{"0b102", "1:1: invalid digit '2' in binary literal"},
+		{"0o78", "1:1: invalid digit '8' in octal literal"},
+		{"1__000", "1:1: '_' must separate successive digits"},
+		{"1_000_", "1:1: '_' must separate successive digits"},
+		{"1_.5", "1:1: '_' must separate successive digits"},
+		{"0x1_0000_0000_0000_0000", "1:1: integer literal 0x1_0000_0000_0000_0000 out of range (must fit in a signed 64-bit integer)"},
+		{"1e400", "1:1: float literal 1e400 out of range"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParserProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5e2;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParserProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statement[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.FloatLiteral, got %T", stmt.Expression)
+	}
+
+	if literal.TokenLiteral() != "2.5e2" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "2.5e2", literal.TokenLiteral())
+	}
+
+	if literal.Value != 250 {
+		t.Errorf("literal.Value not 250, got=%g", literal.Value)
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -247,6 +344,23 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a,b,1,(2 * 3),(4 + 5),add(6,(7 * 8)))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1,2,3,4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])),(b[1]),(2 * ([1,2][1])))"},
+		{"a <= b == b >= c", "((a <= b) == (b >= c))"},
+		{"a < b && b < c", "((a < b) && (b < c))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"!a || b == c", "((!a) || (b == c))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b << c + d", "(a & (b << (c + d)))"},
+		{"a >> 1 == b | c", "((a >> 1) == (b | c))"},
+		{"~a & b", "((~a) & b)"},
+		{"x = a + b", "(x = (a + b))"},
+		{"x = y = z", "(x = (y = z))"},
+		{"x += a || b", "(x += (a || b))"},
+		{"a[i] = f(x)", "((a[i]) = f(x))"},
 	}
 
 	for _, tt := range tests {
@@ -520,7 +634,8 @@ func TestParsingHashLiteralsStringKeys(t *testing.T)  {
 		"three" : 3,
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -577,7 +692,8 @@ func TestParsingHashLiteralBooleanKeys(t *testing.T)  {
 		t.Errorf("hash.Pairs has wrong length, got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		boolean, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.Boolean, got=%T", key)
@@ -610,7 +726,8 @@ func TestParsingHashLiteralIntegerKeys(t *testing.T)  {
 		"3":3,
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		integer, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.Boolean, got=%T", key)
@@ -650,7 +767,8 @@ func TestParsingHashLiteralsWithExpression(t *testing.T)  {
 		},
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -753,7 +871,7 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	}
 
 	if integ.TokenLiteral() != fmt.Sprintf("%d", value) {
-		t.Errorf("integ.TokenLiteral() not %d. got=%d", value, integ.TokenLiteral())
+		t.Errorf("integ.TokenLiteral() not %d. got=%s", value, integ.TokenLiteral())
 		return false
 	}
 	return true
@@ -772,7 +890,7 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	}
 
 	if boolean.TokenLiteral() != fmt.Sprintf("%t", value) {
-		t.Errorf("boolean.TokenLiteral is not %t. got=%t", value, boolean.TokenLiteral())
+		t.Errorf("boolean.TokenLiteral is not %t. got=%s", value, boolean.TokenLiteral())
 		return false
 	}
 	return true
@@ -799,3 +917,430 @@ func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, ope
 
 	return true
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "script.gc:1:7: expected next token to be =; got INT instead"},
+		{"let x = 1;\nlet y = );", "script.gc:2:9: no prefix parse function for ) found"},
+		{"\n\n  99999999999999999999", "script.gc:3:3: integer literal 99999999999999999999 out of range (must fit in a signed 64-bit integer)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("script.gc", tt.input)
+		p := New(l)
+		p.ParserProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, [2, 3][0])"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParserProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if let.Pos().String() != "1:1" || let.End().String() != "3:2" {
+		t.Errorf("let statement span wrong. got=%s-%s", let.Pos(), let.End())
+	}
+
+	fn := let.Value.(*ast.FunctionLiteral)
+	infix := fn.Body.Statements[0].(*ast.ExpressionStatement).Expression
+	if infix.Pos().String() != "2:3" || infix.End().String() != "2:8" {
+		t.Errorf("infix expression span wrong. got=%s-%s", infix.Pos(), infix.End())
+	}
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if call.Pos().String() != "4:1" || call.End().String() != "4:18" {
+		t.Errorf("call expression span wrong. got=%s-%s", call.Pos(), call.End())
+	}
+
+	index := call.Arguments[1]
+	if index.Pos().String() != "4:8" || index.End().String() != "4:17" {
+		t.Errorf("index expression span wrong. got=%s-%s", index.Pos(), index.End())
+	}
+}
+
+func TestCommentsAreSkipped(t *testing.T) {
+	input := `// add two numbers
+let add = fn(x, y) { /* sum */ x + y }; // done
+add(1, /* two */ 2)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParserProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	if program.String() != "let add = fn(x,y)(x + y);add(1,2)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	input := "let a = 1;\nlet s = \"unterminated"
+
+	l := lexer.NewFile("s.gc", input)
+	p := New(l)
+	p.ParserProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error. got=%d (%v)", len(errors), errors)
+	}
+
+	if errors[0] != "s.gc:2:9: unterminated string literal" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += y;", "x", "+=", "y"},
+		{"x **= 2;", "x", "**=", 2},
+		{"x >>= 1;", "x", ">>=", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParserProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifierExpression(t, exp.Target, tt.target) {
+			return
+		}
+
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.operator, exp.Operator)
+		}
+
+		if !testLiteralExpression(t, exp.Value, tt.value) {
+			return
+		}
+	}
+
+	l := lexer.New("1 + 2 = 3")
+	p := New(l)
+	p.ParserProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "1:1: invalid assignment target: (1 + 2)" {
+		t.Errorf("expected invalid assignment target error. got=%v", p.Errors())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x += 1; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParserProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in items) { if (item) { continue; } break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParserProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifierExpression(t, stmt.Variable, "item") {
+		return
+	}
+
+	if !testIdentifierExpression(t, stmt.Iterable, "items") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[1] is not *ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break is not in a loop"},
+		{"if (true) { continue }", "1:13: continue is not in a loop"},
+		{"while (true) { let f = fn() { break; }; }", "1:31: break is not in a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParserProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x 5; let y = 10; let = 3;", []string{
+			"1:7: expected next token to be =; got INT instead",
+			"1:26: expected next token to be IDENT; got = instead",
+		}},
+		{"let a = (1 + ; let b = 2 +; b", []string{
+			"1:14: no prefix parse function for ; found",
+			"1:27: no prefix parse function for ; found",
+		}},
+		{"if (x) { 1 + } let y = 2", []string{
+			"1:14: no prefix parse function for } found",
+		}},
+		{"let h = {1: , 2: 3}; let z = 1 $ 2;", []string{
+			"1:13: no prefix parse function for , found",
+			"1:32: illegal character \"$\"",
+		}},
+		{"let f = fn(x) { x +", []string{
+			"1:20: no prefix parse function for EOF found",
+		}},
+		{"let f = fn(x) { x", []string{
+			"1:18: expected next token to be }; got EOF instead",
+		}},
+		{"let n = 0x;", []string{
+			"1:9: hexadecimal literal has no digits",
+		}},
+		// the ';' after a bad statement is left for recovery to stop at
+		{"let f = fn(x) { x + }; let y = 2; y", []string{
+			"1:21: no prefix parse function for } found",
+		}},
+		{"return fn() { 1 + }; let y = 2;", []string{
+			"1:19: no prefix parse function for } found",
+		}},
+		{"fn() { 1 + }; let y = 2;", []string{
+			"1:12: no prefix parse function for } found",
+		}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParserProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+
+		// must not panic on a partially parsed program
+		_ = program.String()
+	}
+}
+
+func TestErrorRecoveryAfterFunctionLiteral(t *testing.T) {
+	input := "let f = fn(x) { x + }; let y = 2; y"
+
+	p := New(lexer.New(input))
+	program := p.ParserProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error. got=%v", p.Errors())
+	}
+
+	if got := program.String(); got != "let f = fn(x)<bad statement>;let y = 2;y" {
+		t.Errorf("wrong program. got=%q", got)
+	}
+}
+
+func TestErrorRecoveryKeepsGoodStatements(t *testing.T) {
+	input := "let a = 1; let b = ); let c = 3; if (c) { let d = ; } let e = 5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParserProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Fatalf("expected 2 errors. got=%v", p.Errors())
+	}
+
+	kinds := []string{"*ast.LetStatement", "*ast.BadStatement", "*ast.LetStatement", "*ast.ExpressionStatement", "*ast.LetStatement"}
+	if len(program.Statements) != len(kinds) {
+		t.Fatalf("wrong number of statements. want=%d, got=%d (%s)", len(kinds), len(program.Statements), program)
+	}
+	for i, kind := range kinds {
+		if got := fmt.Sprintf("%T", program.Statements[i]); got != kind {
+			t.Errorf("statement %d has wrong type. want=%s, got=%s", i, kind, got)
+		}
+	}
+
+	bad := program.Statements[1]
+	if bad.Pos().Column != 12 || bad.End().Column != 22 {
+		t.Errorf("wrong BadStatement span. got=%s-%s", bad.Pos(), bad.End())
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := "let x 5;\nlet f = fn() { x"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParserProgram()
+
+	diags := p.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics. got=%v", p.Errors())
+	}
+
+	if diags[0].Code != "E0010" || diags[0].Span.Start.String() != "1:7" || diags[0].Span.End.String() != "1:8" {
+		t.Errorf("wrong first diagnostic. got=%+v", diags[0])
+	}
+
+	d := diags[1]
+	if d.Code != "E0010" || d.Span.Start.String() != "2:17" {
+		t.Errorf("wrong second diagnostic. got=%+v", d)
+	}
+	if len(d.Related) != 1 || d.Related[0].Span.Start.String() != "2:14" {
+		t.Errorf("expected the unclosed '{' as related span. got=%+v", d.Related)
+	}
+
+	if p.Errors()[0] != "1:7: expected next token to be =; got INT instead" {
+		t.Errorf("Errors() out of sync with Diagnostics(). got=%q", p.Errors()[0])
+	}
+}
+
+func TestHashLiteralOrder(t *testing.T) {
+	input := `{"z": 1, "a": 2, "m": 3, true: 4, 0: 5}`
+
+	for i := 0; i < 10; i++ {
+		p := New(lexer.New(input))
+		program := p.ParserProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != "{z:1, a:2, m:3, true:4, 0:5}" {
+			t.Fatalf("hash literal not printed in source order. got=%q", got)
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2) { a }", "fn(a,b = 2)a"},
+		{"fn(a = 1, b = a * 2) { a }", "fn(a = 1,b = (a * 2))a"},
+		{"fn(...rest) { rest }", "fn(...rest)rest"},
+		{"fn(a, b = 1, ...rest) { a }", "fn(a,b = 1,...rest)a"},
+		{"f(1, ...xs)", "f(1,...xs)"},
+		{"f(...[1, 2], ...g())", "f(...[1,2],...g())"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParserProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) { a }", "1:11: parameter b without a default follows a parameter with a default"},
+		{"fn(...rest, a) { a }", "1:11: rest parameter ...rest must be the last parameter"},
+		{"fn(1) { 1 }", "1:4: expected next token to be IDENT; got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParserProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
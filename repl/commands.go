@@ -0,0 +1,132 @@
+package repl
+
+import (
+	"GoClang/ast"
+	"GoClang/diag"
+	"GoClang/lexer"
+	"GoClang/object"
+	"GoClang/parser"
+	"GoClang/token"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type command struct {
+	name string
+	args string // argument synopsis for :help
+	help string
+	run  func(s *session, arg string) bool // false ends the session
+}
+
+var commands []command
+
+func init() {
+	// assigned in init because :help refers to commands
+	commands = []command{
+		{"tokens", "<src>", "print the tokens of src", (*session).tokensCommand},
+		{"ast", "<src>", "print the syntax tree of src", (*session).astCommand},
+		{"env", "", "list the bindings in the environment", (*session).envCommand},
+		{"load", "<file>", "run a file in the current environment", (*session).loadCommand},
+		{"reset", "", "discard all bindings", (*session).resetCommand},
+		{"time", "<src>", "run src and report how long it took", (*session).timeCommand},
+		{"help", "", "list the commands", (*session).helpCommand},
+		{"quit", "", "leave the REPL", func(*session, string) bool { return false }},
+	}
+}
+
+// command runs a ":name arg" line and reports whether the session goes on.
+// A unique prefix of a command name is accepted, so :q quits.
+func (s *session) command(line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	arg = strings.TrimSpace(arg)
+
+	var found []command
+	for _, c := range commands {
+		if c.name == name {
+			found = []command{c}
+			break
+		}
+		if name != "" && strings.HasPrefix(c.name, name) {
+			found = append(found, c)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		fmt.Fprintf(s.out, "unknown command :%s (type :help for a list)\n", name)
+		return true
+	case 1:
+		return found[0].run(s, arg)
+	}
+
+	names := make([]string, len(found))
+	for i, c := range found {
+		names[i] = ":" + c.name
+	}
+	fmt.Fprintf(s.out, "ambiguous command :%s (%s)\n", name, strings.Join(names, ", "))
+	return true
+}
+
+func (s *session) tokensCommand(src string) bool {
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(s.out, "%-6s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	diag.RenderAll(s.out, src, l.Diagnostics())
+	return true
+}
+
+func (s *session) astCommand(src string) bool {
+	p := parser.New(lexer.New(src))
+	program := p.ParserProgram()
+	ast.Fprint(s.out, program)
+	diag.RenderAll(s.out, src, p.Diagnostics())
+	return true
+}
+
+func (s *session) envCommand(string) bool {
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+	}
+	return true
+}
+
+func (s *session) loadCommand(filename string) bool {
+	if filename == "" {
+		fmt.Fprintln(s.out, "usage: :load <file>")
+		return true
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return true
+	}
+	s.run(filename, string(src))
+	return true
+}
+
+func (s *session) resetCommand(string) bool {
+	s.env = object.NewEnviroment()
+	return true
+}
+
+func (s *session) timeCommand(src string) bool {
+	start := time.Now()
+	s.eval(src)
+	fmt.Fprintf(s.out, "time: %s\n", time.Since(start))
+	return true
+}
+
+func (s *session) helpCommand(string) bool {
+	for _, c := range commands {
+		fmt.Fprintf(s.out, "  :%-16s %s\n", strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+	return true
+}
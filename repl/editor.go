@@ -0,0 +1,470 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads one line of input after showing a prompt.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scanReader reads lines from a pipe or file, without editing.
+type scanReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scanReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// Keys. Control keys are their ASCII codes; keys sent as escape sequences
+// get values above the Unicode range.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+const (
+	keyUp = unicode.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// editor is a line editor for terminals in raw mode. It supports cursor
+// movement, Emacs-style editing keys, history browsing with the arrow keys,
+// reverse history search with Ctrl-R and completion with Tab.
+type editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *history
+
+	// complete returns the completions of the word before the cursor.
+	complete func(word string) []string
+
+	// raw switches the terminal to raw mode for the duration of a ReadLine
+	// and returns a function that switches it back. nil leaves the mode
+	// alone.
+	raw func() (func() error, error)
+
+	pending rune // key to handle before reading more input, if not 0
+}
+
+// newTerminalEditor returns an editor for in if it is a terminal that can be
+// put into raw mode. Its history is empty and kept in memory only.
+func newTerminalEditor(in io.Reader, out io.Writer, complete func(string) []string) (*editor, bool) {
+	f, ok := in.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	restore, err := makeRaw(f.Fd())
+	if err != nil {
+		return nil, false
+	}
+	restore()
+
+	return &editor{
+		in:       bufio.NewReader(f),
+		out:      out,
+		history:  &history{},
+		complete: complete,
+		raw:      func() (func() error, error) { return makeRaw(f.Fd()) },
+	}, true
+}
+
+// lineState is the line being edited.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int // cursor position in buf
+
+	hist  int    // history entry shown, len(entries) for the new line
+	draft []rune // the new line, kept while browsing history
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	ls := &lineState{prompt: prompt, hist: len(e.history.entries)}
+	e.refresh(ls)
+
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			if err == io.EOF && len(ls.buf) != 0 {
+				err = nil
+				key = keyEnter
+			} else {
+				return "", err
+			}
+		}
+
+		switch key {
+		case keyEnter, keyLineFeed:
+			return e.accept(ls), nil
+
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+
+		case keyCtrlD:
+			if len(ls.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			ls.deleteForward()
+
+		case keyCtrlA, keyHome:
+			ls.pos = 0
+		case keyCtrlE, keyEnd:
+			ls.pos = len(ls.buf)
+		case keyCtrlB, keyLeft:
+			if ls.pos > 0 {
+				ls.pos--
+			}
+		case keyCtrlF, keyRight:
+			if ls.pos < len(ls.buf) {
+				ls.pos++
+			}
+
+		case keyBackspace, keyCtrlH:
+			if ls.pos > 0 {
+				ls.buf = append(ls.buf[:ls.pos-1], ls.buf[ls.pos:]...)
+				ls.pos--
+			}
+		case keyDelete:
+			ls.deleteForward()
+		case keyCtrlK:
+			ls.buf = ls.buf[:ls.pos]
+		case keyCtrlU:
+			ls.buf = append([]rune{}, ls.buf[ls.pos:]...)
+			ls.pos = 0
+		case keyCtrlW:
+			start := ls.pos
+			for start > 0 && unicode.IsSpace(ls.buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(ls.buf[start-1]) {
+				start--
+			}
+			ls.buf = append(ls.buf[:start], ls.buf[ls.pos:]...)
+			ls.pos = start
+
+		case keyCtrlP, keyUp:
+			e.showHistory(ls, ls.hist-1)
+		case keyCtrlN, keyDown:
+			e.showHistory(ls, ls.hist+1)
+
+		case keyCtrlR:
+			accepted, err := e.search(ls)
+			if err != nil {
+				return "", err
+			}
+			if accepted {
+				return e.accept(ls), nil
+			}
+
+		case keyTab:
+			e.completeWord(ls)
+
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+
+		default:
+			if unicode.IsPrint(key) {
+				ls.insert(key)
+			}
+		}
+		e.refresh(ls)
+	}
+}
+
+// accept ends editing and records the line in the history.
+func (e *editor) accept(ls *lineState) string {
+	ls.pos = len(ls.buf)
+	e.refresh(ls)
+	io.WriteString(e.out, "\r\n")
+
+	line := string(ls.buf)
+	e.history.add(line)
+	return line
+}
+
+// refresh redraws the line and puts the cursor back by writing the text up
+// to it a second time, which lets the terminal handle wide characters.
+func (e *editor) refresh(ls *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r%s%s", ls.prompt, string(ls.buf), ls.prompt, string(ls.buf[:ls.pos]))
+}
+
+func (ls *lineState) insert(r rune) {
+	ls.buf = append(ls.buf, 0)
+	copy(ls.buf[ls.pos+1:], ls.buf[ls.pos:])
+	ls.buf[ls.pos] = r
+	ls.pos++
+}
+
+func (ls *lineState) deleteForward() {
+	if ls.pos < len(ls.buf) {
+		ls.buf = append(ls.buf[:ls.pos], ls.buf[ls.pos+1:]...)
+	}
+}
+
+func (ls *lineState) set(line []rune) {
+	ls.buf = append([]rune{}, line...)
+	ls.pos = len(ls.buf)
+}
+
+// showHistory replaces the line with history entry i, where i equal to the
+// number of entries is the line being typed.
+func (e *editor) showHistory(ls *lineState, i int) {
+	entries := e.history.entries
+	if i < 0 || i > len(entries) || i == ls.hist {
+		return
+	}
+	if ls.hist == len(entries) {
+		ls.draft = append([]rune{}, ls.buf...)
+	}
+
+	ls.hist = i
+	if i == len(entries) {
+		ls.set(ls.draft)
+	} else {
+		ls.set([]rune(entries[i]))
+	}
+}
+
+// search runs a reverse incremental history search. Typing narrows the
+// search, Ctrl-R finds the next older match, Enter runs the match, Ctrl-G
+// or Ctrl-C gives up and any other key starts editing the match. It reports
+// whether the match was accepted with Enter.
+func (e *editor) search(ls *lineState) (bool, error) {
+	entries := e.history.entries
+	original := append([]rune{}, ls.buf...)
+	var query []rune
+	match := len(entries)
+
+	// find looks for the newest entry before from that contains the query.
+	find := func(from int) {
+		if from > len(entries) {
+			from = len(entries)
+		}
+		for i := from - 1; i >= 0; i-- {
+			if strings.Contains(entries[i], string(query)) {
+				match = i
+				return
+			}
+		}
+	}
+
+	for {
+		found := string(original)
+		if match < len(entries) {
+			found = entries[match]
+		}
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), found)
+
+		key, err := e.readKey()
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case key == keyCtrlR:
+			find(match)
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = len(entries)
+				find(match)
+			}
+		case key == keyCtrlG || key == keyCtrlC:
+			ls.set(original)
+			return false, nil
+		case key == keyEnter || key == keyLineFeed:
+			ls.set([]rune(found))
+			return true, nil
+		case key < keyUp && unicode.IsPrint(key):
+			query = append(query, key)
+			// the current match may still contain the longer query
+			find(match + 1)
+		default:
+			ls.set([]rune(found))
+			e.pending = key
+			return false, nil
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. A single completion is
+// inserted; otherwise the longest common prefix is, and if that adds nothing
+// the candidates are listed.
+func (e *editor) completeWord(ls *lineState) {
+	if e.complete == nil {
+		return
+	}
+	start := ls.pos
+	for start > 0 && isWordRune(ls.buf[start-1]) {
+		start--
+	}
+	word := string(ls.buf[start:ls.pos])
+	if word == "" {
+		return
+	}
+
+	candidates := e.complete(word)
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	if len(candidates) == 1 {
+		prefix += " "
+	}
+
+	if len(prefix) > len(word) {
+		for _, r := range prefix[len(word):] {
+			ls.insert(r)
+		}
+		return
+	}
+	fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == ':'
+}
+
+// completions returns the sorted, distinct entries of names that start with
+// word.
+func completions(word string, names ...[]string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, list := range names {
+		for _, name := range list {
+			if strings.HasPrefix(name, word) && name != word && !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// readKey reads a key press, decoding the escape sequences sent for arrow,
+// Home, End and Delete keys.
+func (e *editor) readKey() (rune, error) {
+	if e.pending != 0 {
+		key := e.pending
+		e.pending = 0
+		return key, nil
+	}
+
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+
+	// CSI parameters, then a final byte in '@'..'~'
+	var param []rune
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if r >= '@' && r <= '~' {
+			break
+		}
+		param = append(param, r)
+	}
+
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch string(param) {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+	return keyUnknown, nil
+}
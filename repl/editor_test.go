@@ -0,0 +1,132 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(input string, entries ...string) *editor {
+	return &editor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     io.Discard,
+		history: &history{entries: entries},
+		complete: func(word string) []string {
+			return completions(word, []string{"len", "let", "first", "中文"})
+		},
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		history  []string
+		expected string
+	}{
+		{"plain", "let x = 1\r", nil, "let x = 1"},
+		{"line feed", "abc\n", nil, "abc"},
+		{"left and insert", "ac\x1b[DX\r", nil, "aXc"},
+		{"home and end", "bc\x1b[Ha\x1b[Fd\r", nil, "abcd"},
+		{"ctrl-a and ctrl-e", "bc\x01a\x05d\r", nil, "abcd"},
+		{"backspace", "abcd\x7f\x7f\r", nil, "ab"},
+		{"delete", "abc\x1b[D\x1b[D\x1b[3~\r", nil, "ac"},
+		{"ctrl-k", "abcd\x02\x02\x0b\r", nil, "ab"},
+		{"ctrl-u", "abcd\x02\x15\r", nil, "d"},
+		{"ctrl-w", "let x = foo\x17bar\r", nil, "let x = bar"},
+		{"unicode", "\"中文\"\x1b[D\x1b[D字\r", nil, "\"中字文\""},
+		{"history up", "\x1b[A\r", []string{"one", "two"}, "two"},
+		{"history up twice", "\x1b[A\x1b[A\r", []string{"one", "two"}, "one"},
+		{"history keeps draft", "dra\x1b[A\x1b[Bft\r", []string{"one"}, "draft"},
+		{"ctrl-p past start", "\x10\x10\x10\r", []string{"one"}, "one"},
+		{"search", "\x12wo\r", []string{"one", "two", "three"}, "two"},
+		{"search older", "\x12e\x12\r", []string{"one", "two", "three"}, "one"},
+		{"search then edit", "\x12tw\x1b[C!\r", []string{"one", "two"}, "two!"},
+		{"search cancel", "x\x12tw\x07\r", []string{"two"}, "x"},
+		{"complete unique", "fi\t(a)\r", nil, "first (a)"},
+		{"complete common prefix", "l\te\r", nil, "lee"},
+		{"complete unicode", "中\t\r", nil, "中文 "},
+		{"eof ends line", "abc", nil, "abc"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.input, tt.history...)
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%s: wrong line. expected=%q, got=%q", tt.name, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorControl(t *testing.T) {
+	e := newTestEditor("abc\x03\x04")
+
+	if _, err := e.ReadLine(">> "); err != errInterrupted {
+		t.Errorf("expected errInterrupted on Ctrl-C. got=%v", err)
+	}
+	if _, err := e.ReadLine(">> "); err != io.EOF {
+		t.Errorf("expected io.EOF on Ctrl-D. got=%v", err)
+	}
+}
+
+func TestEditorCompletionList(t *testing.T) {
+	var out bytes.Buffer
+	e := newTestEditor("le\t\r")
+	e.out = &out
+
+	if line, _ := e.ReadLine(">> "); line != "le" {
+		t.Errorf("wrong line. got=%q", line)
+	}
+	if !strings.Contains(out.String(), "\r\nlen  let\r\n") {
+		t.Errorf("candidates not listed. got=%q", out.String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	h := loadHistory(path)
+	h.add("let a = 1;")
+	h.add("let a = 1;")
+	h.add("   ")
+	h.add("a + 1")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "let a = 1;\na + 1\n" {
+		t.Errorf("wrong history file. got=%q", data)
+	}
+
+	h = loadHistory(path)
+	if len(h.entries) != 2 || h.entries[1] != "a + 1" {
+		t.Errorf("history not reloaded. got=%q", h.entries)
+	}
+
+	var lines []string
+	for i := 0; i < maxHistory+10; i++ {
+		lines = append(lines, strings.Repeat("x", i%7+1))
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	if h = loadHistory(path); len(h.entries) != maxHistory {
+		t.Errorf("history not trimmed. got %d entries", len(h.entries))
+	}
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != maxHistory+10 {
+		t.Errorf("history file changed by loading. got %d lines", strings.Count(string(data), "\n"))
+	}
+
+	h.add("last")
+	data, _ = os.ReadFile(path)
+	if strings.Count(string(data), "\n") != maxHistory || !strings.HasSuffix(string(data), "\nlast\n") {
+		t.Errorf("history file not trimmed on write. got %d lines", strings.Count(string(data), "\n"))
+	}
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	HISTORY_FILE = ".goclang_history"
+	maxHistory   = 1000
+)
+
+// history holds the lines entered so far, oldest first. Lines are appended to
+// the file at path as they are added, so that they survive the session. The
+// file is only rewritten, to its newest maxHistory entries, once it is full.
+type history struct {
+	entries []string
+	path    string // "" keeps the history in memory only
+}
+
+// defaultHistoryPath returns ~/.goclang_history, or "" if there is no home
+// directory.
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// loadHistory reads the history file at path, keeping the newest maxHistory
+// entries. The file itself is left as it is. A missing or unreadable file
+// gives an empty history.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// add records line unless it is blank or repeats the previous entry.
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return
+	}
+
+	h.entries = append(h.entries, line)
+	full := len(h.entries) > maxHistory
+	if full {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	if full {
+		// rewrite the file so that it does not grow without bound
+		os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
@@ -1,40 +1,168 @@
 package repl
 
 import (
+	"GoClang/diag"
 	"GoClang/evaluator"
 	"GoClang/lexer"
 	"GoClang/object"
 	"GoClang/parser"
+	"GoClang/token"
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT       = ">> "
+	CONTINUATION = ".. "
+)
 
+// Start reads programs from in and prints their values to out. Input that
+// leaves a bracket, raw string or block comment open is continued on the
+// next line; an empty line ends the input early. Lines starting with ':' are
+// meta commands, see :help.
+//
+// When in is a terminal, lines are read with a line editor that keeps its
+// history in ~/.goclang_history.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnviroment()
+	s := newSession(out)
+
+	var lines lineReader = &scanReader{scanner: bufio.NewScanner(in), out: out}
+	if ed, ok := newTerminalEditor(in, out, s.completions); ok {
+		ed.history = loadHistory(defaultHistoryPath())
+		lines = ed
+	}
 
+	var input strings.Builder
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		prompt := PROMPT
+		if input.Len() != 0 {
+			prompt = CONTINUATION
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParserProgram()
-		if len(p.Errors()) != 0 {
+		line, err := lines.ReadLine(prompt)
+		if err == errInterrupted {
+			input.Reset()
+			continue
+		}
+		if err != nil {
+			if input.Len() != 0 {
+				io.WriteString(out, "\n")
+				s.eval(input.String())
+			}
 			return
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		if input.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !s.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+		if input.Len() != 0 && strings.TrimSpace(line) == "" {
+			s.eval(input.String())
+			input.Reset()
+			continue
+		}
+
+		input.WriteString(line)
+		input.WriteString("\n")
+		if incomplete(input.String()) {
+			continue
+		}
+
+		s.eval(input.String())
+		input.Reset()
+	}
+}
+
+// session is the state kept between inputs.
+type session struct {
+	out io.Writer
+	env *object.Environment
+
+	// sources maps file names to their text. Each input is named "<inputN>"
+	// and kept, because a runtime error may point into a function defined
+	// by an earlier input.
+	sources map[string]string
+	inputs  int
+}
+
+func newSession(out io.Writer) *session {
+	return &session{
+		out:     out,
+		env:     object.NewEnviroment(),
+		sources: make(map[string]string),
+	}
+}
+
+// completions returns the keywords, builtins, bound names and, for words
+// starting with ':', meta commands that complete word.
+func (s *session) completions(word string) []string {
+	if strings.HasPrefix(word, ":") {
+		names := make([]string, len(commands))
+		for i, c := range commands {
+			names[i] = ":" + c.name
+		}
+		return completions(word, names)
+	}
+	return completions(word, token.Keywords(), evaluator.BuiltinNames(), s.env.Names())
+}
+
+// eval runs src as the next input.
+func (s *session) eval(src string) {
+	s.inputs++
+	s.run(fmt.Sprintf("<input%d>", s.inputs), src)
+}
+
+// run runs src in the session environment, printing its value, or its
+// errors rendered with the offending source line.
+func (s *session) run(filename, src string) {
+	s.sources[filename] = src
+
+	l := lexer.NewFile(filename, src)
+	p := parser.New(l)
+	program := p.ParserProgram()
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		diag.RenderAll(s.out, src, diags)
+		return
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if evaluated == nil {
+		return
+	}
+	if err, ok := evaluated.(*object.Error); ok {
+		d := err.Diagnostic()
+		diag.Render(s.out, s.sources[d.Span.Start.Filename], d)
+		return
+	}
+	io.WriteString(s.out, evaluated.Inspect())
+	io.WriteString(s.out, "\n")
+}
+
+// incomplete reports whether src ends inside an open bracket, raw string or
+// block comment, so that more lines are needed.
+func incomplete(src string) bool {
+	l := lexer.New(src)
+	depth := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+
+	for _, d := range l.Diagnostics() {
+		if d.Code == diag.ErrUnterminatedRaw || d.Code == diag.ErrUnterminatedComment {
+			return true
 		}
 	}
+	return false
 }
@@ -0,0 +1,123 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	input := strings.Join([]string{
+		"let add = fn(a, b) {",
+		"  a + b",
+		"};",
+		"add(1, 2)",
+		"let x = ;",
+		"add(1, true)",
+		"let s = `line one",
+		"line two`;",
+		"len(s)",
+		"[1,",
+		"",
+		"x",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := `>> .. .. >> 3
+>> error[E0011]: no prefix parse function for ; found
+ --> <input3>:1:9
+  |
+1 | let x = ;
+  |         ^
+>> error[E0102]: type mismatch: INTEGER + BOOLEAN
+ --> <input1>:2:3
+  |
+2 |   a + b
+  |   ^^^^^
+  = in add(1, true), called at <input4>:1:1
+>> .. >> 17
+>> .. error[E0011]: no prefix parse function for EOF found
+ --> <input7>:2:1
+  |
+2 | 
+  | ^
+>> error[E0100]: identifier not found: x
+ --> <input8>:1:1
+  |
+1 | x
+  | ^
+>> `
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 1;", false},
+		{"fn(x) {", true},
+		{"fn(x) { x }", false},
+		{"[1, (2", true},
+		{"}", false},
+		{"`raw", true},
+		{"\"plain", false},
+		{"/* comment", true},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) = %t, want %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "lib.gc")
+	if err := os.WriteFile(file, []byte("let double = fn(x) { x * 2 };"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		contains []string
+		excludes []string
+	}{
+		{":tokens let x = 1", []string{"1:1    LET        \"let\"\n", "1:9    INT        \"1\"\n", "EOF"}, nil},
+		{":tokens \"abc", []string{"error[E0003]: unterminated string literal"}, nil},
+		{":ast 1 + 2", []string{"*ast.InfixExpression {", "Operator: \"+\""}, nil},
+		{":ast let = 1", []string{"*ast.BadStatement {", "error[E0010]"}, nil},
+		{"let a = 1;\nlet b = \"x\";\n:env", []string{"a = 1\nb = x\n"}, nil},
+		{":load " + file + "\ndouble(21)", []string{"42\n"}, nil},
+		{":load " + filepath.Join(dir, "missing.gc"), []string{"no such file"}, nil},
+		{"let a = 1;\n:reset\n:env\na", []string{"identifier not found: a"}, []string{"a = 1"}},
+		{":time 6 * 7", []string{"42\ntime: "}, nil},
+		{":help", []string{":tokens <src>", ":quit"}, nil},
+		{":q\n1 + 1", nil, []string{"2"}},
+		{":frob", []string{"unknown command :frob"}, nil},
+		{":t 1", []string{"ambiguous command :t (:tokens, :time)"}, nil},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		for _, want := range tt.contains {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("output for %q does not contain %q. got:\n%s", tt.input, want, out.String())
+			}
+		}
+		for _, unwanted := range tt.excludes {
+			if strings.Contains(out.String(), unwanted) {
+				t.Errorf("output for %q contains %q. got:\n%s", tt.input, unwanted, out.String())
+			}
+		}
+	}
+}
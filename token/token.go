@@ -1,10 +1,59 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type Tokentype string
 
+// Position describes a location in the source. Line and Column are 1-based;
+// a Position with Line 0 is invalid.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns "file:line:col", "line:col" when there is no file name, or
+// "-" for an invalid position.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Comment is a `// line` or `/* block */` comment. Text includes the comment
+// markers.
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
+}
+
 type Token struct {
 	Type    Tokentype
 	Literal string
+	Pos     Position // position of the first character
+	End     Position // position just after the last character
+
+	// Leading holds the comments between the previous token's trailing
+	// comments and this token. Trailing holds the comments that start on
+	// the same line after this token.
+	Leading  []Comment
+	Trailing []Comment
 }
 
 const (
@@ -13,6 +62,7 @@ const (
 
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	//Operators
@@ -22,15 +72,42 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
+
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	TILDE       = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	//Compound assignment
+	PLUS_ASSIGN        = "+="
+	MINUS_ASSIGN       = "-="
+	ASTERISK_ASSIGN    = "*="
+	SLASH_ASSIGN       = "/="
+	PERCENT_ASSIGN     = "%="
+	POWER_ASSIGN       = "**="
+	AMPERSAND_ASSIGN   = "&="
+	PIPE_ASSIGN        = "|="
+	CARET_ASSIGN       = "^="
+	SHIFT_LEFT_ASSIGN  = "<<="
+	SHIFT_RIGHT_ASSIGN = ">>="
 
 	//Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
-	COLON = ":"
+	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -47,16 +124,26 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]Tokentype{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) Tokentype {
@@ -65,3 +152,13 @@ func LookupIdent(ident string) Tokentype {
 	}
 	return IDENT
 }
+
+// Keywords returns the reserved words in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}